@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChaosFaults are the faults that a chaos campaign can inject
+var ChaosFaults = []string{"stop", "pause", "partition", "latency", "link-drop"}
+
+// ChaosSettings describes a randomized fault campaign
+type ChaosSettings struct {
+	Seed                int64              `yaml:"Seed,omitempty"`                // Seed of the random generator, random if 0
+	Duration            time.Duration      `yaml:"Duration,omitempty"`            // Length of the campaign
+	MaxConcurrentFaults int                `yaml:"MaxConcurrentFaults,omitempty"` // Faults active at the same time
+	MinFaultDuration    time.Duration      `yaml:"MinFaultDuration,omitempty"`    // Shortest time before a fault is healed
+	MaxFaultDuration    time.Duration      `yaml:"MaxFaultDuration,omitempty"`    // Longest time before a fault is healed
+	Latency             time.Duration      `yaml:"Latency,omitempty"`             // Delay added by a latency spike
+	Faults              map[string]float64 `yaml:"Faults,omitempty"`              // Rate of every fault, in faults per minute
+	Journal             string             `yaml:"Journal,omitempty"`             // File where the actions are logged
+}
+
+// DefaultChaosSettings returns the settings used when the yaml file doesn't set them
+func DefaultChaosSettings() ChaosSettings {
+	return ChaosSettings{
+		Duration:            10 * time.Minute,
+		MaxConcurrentFaults: 1,
+		MinFaultDuration:    10 * time.Second,
+		MaxFaultDuration:    time.Minute,
+		Latency:             200 * time.Millisecond,
+		Faults:              map[string]float64{"stop": 1, "pause": 1},
+		Journal:             "chaos_journal.jsonl",
+	}
+}
+
+// Merge returns the settings obtained by overriding the receiver with the non zero values of other
+func (c ChaosSettings) Merge(other ChaosSettings) ChaosSettings {
+	if other.Seed != 0 {
+		c.Seed = other.Seed
+	}
+	if other.Duration != 0 {
+		c.Duration = other.Duration
+	}
+	if other.MaxConcurrentFaults != 0 {
+		c.MaxConcurrentFaults = other.MaxConcurrentFaults
+	}
+	if other.MinFaultDuration != 0 {
+		c.MinFaultDuration = other.MinFaultDuration
+	}
+	if other.MaxFaultDuration != 0 {
+		c.MaxFaultDuration = other.MaxFaultDuration
+	}
+	if other.Latency != 0 {
+		c.Latency = other.Latency
+	}
+	if other.Faults != nil {
+		c.Faults = other.Faults
+	}
+	if other.Journal != "" {
+		c.Journal = other.Journal
+	}
+	return c
+}
+
+// Validate checks the consistency of the chaos settings
+// It returns an error if a fault is unknown or a value is out of range
+func (c ChaosSettings) Validate() error {
+	for fault, rate := range c.Faults {
+		known := false
+		for _, f := range ChaosFaults {
+			known = known || f == fault
+		}
+		if !known {
+			return fmt.Errorf("unknown fault %q, the faults are %v", fault, ChaosFaults)
+		}
+		if rate < 0 {
+			return fmt.Errorf("the rate of the fault %s must not be negative", fault)
+		}
+	}
+	if c.Duration < 0 || c.MaxConcurrentFaults < 1 {
+		return fmt.Errorf("the duration must not be negative and at least one fault must be allowed at a time")
+	}
+	if c.MinFaultDuration < time.Second || c.MaxFaultDuration < c.MinFaultDuration {
+		return fmt.Errorf("the fault duration must be at least 1s and the maximum must not be less than the minimum")
+	}
+	return nil
+}
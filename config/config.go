@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -22,7 +23,17 @@ type YamlConfig struct {
 		NumContainers int      `yaml:"NumContainers,omitempty"`
 		NumNetworks   int      `yaml:"NumNetworks,omitempty"`
 		NetMatrix     [][]bool `yaml:"NetMatrix,omitempty"`
+		TopologyFile  string   `yaml:"TopologyFile,omitempty"` // GraphML, DOT or edge list file that replaces NumNetworks and NetMatrix
 	} `yaml:"NetworkSettings"`
+	ResourceSettings    ResourceSettings    `yaml:"ResourceSettings,omitempty"`
+	NodeGroups          []NodeGroup         `yaml:"NodeGroups,omitempty"`
+	SecuritySettings    SecuritySettings    `yaml:"SecuritySettings,omitempty"`
+	HealthCheckSettings HealthCheckSettings `yaml:"HealthCheckSettings,omitempty"`
+	StartupSettings     StartupSettings     `yaml:"StartupSettings,omitempty"`
+	ChaosSettings       ChaosSettings       `yaml:"ChaosSettings,omitempty"`
+	WatchSettings       WatchSettings       `yaml:"WatchSettings,omitempty"`
+	NodeSettings        NodeSettings        `yaml:"NodeSettings,omitempty"`
+	ProjectSettings     ProjectSettings     `yaml:"ProjectSettings,omitempty"`
 }
 
 type Config struct {
@@ -35,7 +46,26 @@ type Config struct {
 	NetworkName    *string
 	ImageName      *string
 	YamlFilePath   *string
+	ApiAddress     *string
+	Privileged     *bool
+	ReadyTimeout   *time.Duration
+	EditMatrix     *bool
+	EventsPath     *string
+	AutoRestart    *string
+	TopologyFile   *string
+	ProjectName    *string
+	SubnetPool     *string
+	Args           []string // Command and its arguments, what follows the options
 	NetMatrix      [][]bool
+	LinkProperties []LinkProperties    // Properties of the links set by the topology file
+	Resources      ResourceSettings    // Default resource limits of the nodes
+	NodeGroups     []NodeGroup         // Per-node overrides of the defaults
+	Security       SecuritySettings    // Security profile of the nodes
+	HealthCheck    HealthCheckSettings // Default health check of the nodes
+	Chaos          ChaosSettings       // Settings of the chaos campaigns
+	Watch          WatchSettings       // Reaction to the changes of the nodes not made by ContainMesh
+	Node           NodeSettings        // Default command and environment of the nodes
+	Project        ProjectSettings     // Name and subnets isolating the environment from the other ones on the host
 }
 
 // ParseYamlConfig reads the yaml file and sets the values of the config struct
@@ -77,6 +107,15 @@ func ParseYamlConfig(config *Config) error {
 	if yamlConf.NetworkSettings.NumLinks != 0 {
 		config.NumLinks = &yamlConf.NetworkSettings.NumLinks
 	}
+	// The topology file replaces the number of networks and the matrix, the one given on the command line wins
+	if *config.TopologyFile == "" && yamlConf.NetworkSettings.TopologyFile != "" {
+		config.TopologyFile = &yamlConf.NetworkSettings.TopologyFile
+	}
+	if *config.TopologyFile != "" {
+		if err := config.ImportTopologyFile(); err != nil {
+			return err
+		}
+	}
 	if yamlConf.NetworkSettings.NetworkName != "" {
 		config.NetworkName = &yamlConf.NetworkSettings.NetworkName
 	}
@@ -86,6 +125,53 @@ func ParseYamlConfig(config *Config) error {
 	config.IgnoreBuild = &yamlConf.ImageSettings.IgnoreBuild
 	config.PullImage = &yamlConf.ImageSettings.PullImage
 
+	// Check the resource limits and the node groups
+	if err := yamlConf.ResourceSettings.Validate(); err != nil {
+		return fmt.Errorf("error in the resource settings: %v", err)
+	}
+	config.Resources = yamlConf.ResourceSettings
+	for _, group := range yamlConf.NodeGroups {
+		if _, err := ParseNodeSelection(group.Nodes, config.TotalNodes()); err != nil {
+			return fmt.Errorf("error in the node group %s: %v", group.Name, err)
+		}
+		if err := config.Resources.Merge(group.Resources).Validate(); err != nil {
+			return fmt.Errorf("error in the resource settings of the node group %s: %v", group.Name, err)
+		}
+		if err := group.HealthCheck.Validate(); err != nil {
+			return fmt.Errorf("error in the health check of the node group %s: %v", group.Name, err)
+		}
+	}
+	config.NodeGroups = yamlConf.NodeGroups
+	if _, err := config.NodeDependencies(); err != nil {
+		return err
+	}
+
+	if err := yamlConf.SecuritySettings.Validate(); err != nil {
+		return fmt.Errorf("error in the security settings: %v", err)
+	}
+	config.Security = yamlConf.SecuritySettings
+
+	if err := yamlConf.HealthCheckSettings.Validate(); err != nil {
+		return fmt.Errorf("error in the health check settings: %v", err)
+	}
+	config.HealthCheck = yamlConf.HealthCheckSettings
+	config.Chaos = config.Chaos.Merge(yamlConf.ChaosSettings)
+	if err := config.Chaos.Validate(); err != nil {
+		return fmt.Errorf("error in the chaos settings: %v", err)
+	}
+	if err := yamlConf.WatchSettings.Validate(); err != nil {
+		return fmt.Errorf("error in the watch settings: %v", err)
+	}
+	config.Watch = yamlConf.WatchSettings
+	config.Node = yamlConf.NodeSettings
+	config.Project = yamlConf.ProjectSettings
+	if yamlConf.StartupSettings.ReadyTimeout < 0 {
+		return fmt.Errorf("the ready timeout must not be negative")
+	}
+	if yamlConf.StartupSettings.ReadyTimeout != 0 {
+		config.ReadyTimeout = &yamlConf.StartupSettings.ReadyTimeout
+	}
+
 	return nil
 }
 
@@ -102,13 +188,49 @@ func ProcessCommandLineArgs() (*Config, error) {
 		IgnoreBuild:    flag.Bool("b", true, "Ignore the build of the image"),
 		PullImage:      flag.Bool("p", false, "Pull the image from the Docker Hub"),
 		YamlFilePath:   flag.String("y", "", "Yaml configuration file name"),
+		Chaos:          DefaultChaosSettings(),
+		ApiAddress:     flag.String("api", "", "Address of the REST API server (e.g. :8080), disabled if empty"),
+		Privileged:     flag.Bool("privileged", false, "Run the containers in privileged mode instead of the least-privilege profile"),
+		ReadyTimeout:   flag.Duration("wait", 0, "Wait until all the containers are healthy, up to the given timeout (e.g. 2m)"),
+		AutoRestart:    flag.String("auto-restart", "", "Restart the containers stopped outside ContainMesh: never, on-failure or always"),
+		EventsPath:     flag.String("events", "", "File where the actions on the containers and networks are logged as JSON lines, by default next to the state file"),
+		TopologyFile:   flag.String("topology", "", "GraphML, DOT or edge list file with the topology of the networks, it replaces the number of networks and the matrix"),
+		EditMatrix:     flag.Bool("matrix", false, "Edit the adjacency matrix before creating the environment, also if it is set in the yaml file"),
+		ProjectName:    flag.String("project", os.Getenv("CONTAINMESH_PROJECT"), "Name of the environment, it isolates its containers, networks and state from the other environments on the host"),
+		SubnetPool:     flag.String("subnet-pool", "", "Range where the subnets of the networks are allocated (default "+DefaultSubnetPool+")"),
 	}
 	flag.Parse()
+	config.Args = flag.Args()
 	if config.YamlFilePath != nil && *config.YamlFilePath != "" {
 		err := ParseYamlConfig(config)
 		if err != nil {
 			return nil, err
 		}
+	} else if *config.TopologyFile != "" {
+		if err := config.ImportTopologyFile(); err != nil {
+			return nil, err
+		}
+	}
+	if *config.Privileged {
+		config.Security.Privileged = true
+	}
+	if *config.AutoRestart != "" {
+		config.Watch.AutoRestart = *config.AutoRestart
+		if err := config.Watch.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if *config.ProjectName != "" {
+		config.Project.Name = *config.ProjectName
+	}
+	if *config.SubnetPool != "" {
+		config.Project.SubnetPool = *config.SubnetPool
+	}
+	if err := config.Project.Validate(); err != nil {
+		return nil, fmt.Errorf("error in the project settings: %v", err)
+	}
+	if err := config.ValidateNames(); err != nil {
+		return nil, err
 	}
 	return config, nil
 }
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resolveDependency returns the nodes referenced by a dependency, the name of a node group or a node selection
+// It returns an error if the dependency is neither a group name nor a valid selection
+func (config *Config) resolveDependency(dependency string) ([]int, error) {
+	for _, group := range config.NodeGroups {
+		if group.Name != "" && group.Name == dependency {
+			return ParseNodeSelection(group.Nodes, config.TotalNodes())
+		}
+	}
+	nodes, err := ParseNodeSelection(dependency, config.TotalNodes())
+	if err != nil {
+		return nil, fmt.Errorf("unknown target %q, it must be a group name or a node selection: %v", dependency, err)
+	}
+	return nodes, nil
+}
+
+// NodeDependencies returns, for every node with dependencies, the sorted list of nodes that must be ready before it starts
+// It returns an error if a dependency can't be resolved or if the dependencies contain a cycle
+func (config *Config) NodeDependencies() (map[int][]int, error) {
+	edges := map[int]map[int]bool{}
+	for _, group := range config.NodeGroups {
+		if len(group.DependsOn) == 0 {
+			continue
+		}
+		nodes, err := ParseNodeSelection(group.Nodes, config.TotalNodes())
+		if err != nil {
+			return nil, fmt.Errorf("error in the node group %s: %v", group.Name, err)
+		}
+		for _, dependency := range group.DependsOn {
+			dependencies, err := config.resolveDependency(dependency)
+			if err != nil {
+				return nil, fmt.Errorf("error in the node group %s: %v", group.Name, err)
+			}
+			for _, node := range nodes {
+				if edges[node] == nil {
+					edges[node] = map[int]bool{}
+				}
+				for _, d := range dependencies {
+					edges[node][d] = true
+				}
+			}
+		}
+	}
+	graph := map[int][]int{}
+	for node, dependencies := range edges {
+		for d := range dependencies {
+			graph[node] = append(graph[node], d)
+		}
+		sort.Ints(graph[node])
+	}
+	if cycle := findCycle(graph); cycle != nil {
+		path := make([]string, len(cycle))
+		for i, node := range cycle {
+			path[i] = strconv.Itoa(node)
+		}
+		return nil, fmt.Errorf("the dependencies contain a cycle: %s", strings.Join(path, " -> "))
+	}
+	return graph, nil
+}
+
+// findCycle searches a cycle in the dependency graph with a depth first visit
+// It returns the nodes of the cycle, starting and ending with the same node, or nil if the graph is acyclic
+func findCycle(graph map[int][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[int]int{}
+	var stack []int
+	var visit func(node int) []int
+	visit = func(node int) []int {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range graph[node] {
+			switch state[next] {
+			case visiting:
+				// The cycle is the part of the stack that starts from next
+				for i := range stack {
+					if stack[i] == next {
+						return append(append([]int{}, stack[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+		return nil
+	}
+	nodes := make([]int, 0, len(graph))
+	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			if cycle := visit(node); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// meshConfig returns a configuration of the given number of networks and nodes per network with the node groups
+func meshConfig(numNetworks int, numContainers int, groups ...NodeGroup) *Config {
+	return &Config{NumNetworks: &numNetworks, NumContainers: &numContainers, NodeGroups: groups}
+}
+
+func TestNodeDependencies(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   map[int][]int
+		err    string
+	}{
+		{
+			name:   "no dependencies",
+			config: meshConfig(1, 3, NodeGroup{Name: "db", Nodes: "0"}),
+			want:   map[int][]int{},
+		},
+		{
+			name: "dag of groups and selections",
+			config: meshConfig(2, 3,
+				NodeGroup{Name: "db", Nodes: "0"},
+				NodeGroup{Name: "app", Nodes: "1-2", DependsOn: []string{"db"}},
+				NodeGroup{Name: "front", Nodes: "3,5", DependsOn: []string{"app", "0"}},
+				NodeGroup{Name: "extra", Nodes: "5", DependsOn: []string{"4"}}),
+			want: map[int][]int{1: {0}, 2: {0}, 3: {0, 1, 2}, 5: {0, 1, 2, 4}},
+		},
+		{
+			name:   "self dependency",
+			config: meshConfig(1, 2, NodeGroup{Name: "a", Nodes: "0-1", DependsOn: []string{"a"}}),
+			err:    "cycle: 0 -> 0",
+		},
+		{
+			name: "two groups depending on each other",
+			config: meshConfig(1, 2,
+				NodeGroup{Name: "a", Nodes: "0", DependsOn: []string{"b"}},
+				NodeGroup{Name: "b", Nodes: "1", DependsOn: []string{"a"}}),
+			err: "cycle: 0 -> 1 -> 0",
+		},
+		{
+			name:   "unknown group",
+			config: meshConfig(1, 2, NodeGroup{Name: "a", Nodes: "0", DependsOn: []string{"db"}}),
+			err:    `unknown target "db"`,
+		},
+		{
+			name:   "selection out of range",
+			config: meshConfig(1, 2, NodeGroup{Name: "a", Nodes: "0", DependsOn: []string{"2"}}),
+			err:    "out of range",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.config.NodeDependencies()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("NodeDependencies() error = %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NodeDependencies() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("NodeDependencies() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph map[int][]int
+		want  []int
+	}{
+		{name: "empty", graph: map[int][]int{}},
+		{name: "chain", graph: map[int][]int{2: {1}, 1: {0}}},
+		{name: "diamond", graph: map[int][]int{3: {1, 2}, 1: {0}, 2: {0}}},
+		{name: "self loop", graph: map[int][]int{4: {4}}, want: []int{4, 4}},
+		{name: "two cycle", graph: map[int][]int{0: {1}, 1: {0}}, want: []int{0, 1, 0}},
+		{name: "cycle after a dag", graph: map[int][]int{0: {1}, 1: {2}, 2: {3}, 3: {1}}, want: []int{1, 2, 3, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := findCycle(test.graph); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("findCycle() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthCheckSettings describes the health check of a node, a zero value means that the setting is not set
+type HealthCheckSettings struct {
+	Command     string        `yaml:"Command,omitempty"`     // Shell command, the node is healthy when it exits with 0
+	Interval    time.Duration `yaml:"Interval,omitempty"`    // Time between two checks, e.g. 5s
+	Timeout     time.Duration `yaml:"Timeout,omitempty"`     // Time after which a check is considered failed
+	Retries     int           `yaml:"Retries,omitempty"`     // Consecutive failures needed to report the node as unhealthy
+	StartPeriod time.Duration `yaml:"StartPeriod,omitempty"` // Time given to the node to start before counting the failures
+}
+
+// StartupSettings describes how the virtual environment is brought up
+type StartupSettings struct {
+	ReadyTimeout time.Duration `yaml:"ReadyTimeout,omitempty"` // Wait until every node is healthy, up to this timeout (0 to disable)
+}
+
+// Merge returns the settings obtained by overriding the receiver with the non zero values of other
+func (h HealthCheckSettings) Merge(other HealthCheckSettings) HealthCheckSettings {
+	if other.Command != "" {
+		h.Command = other.Command
+	}
+	if other.Interval != 0 {
+		h.Interval = other.Interval
+	}
+	if other.Timeout != 0 {
+		h.Timeout = other.Timeout
+	}
+	if other.Retries != 0 {
+		h.Retries = other.Retries
+	}
+	if other.StartPeriod != 0 {
+		h.StartPeriod = other.StartPeriod
+	}
+	return h
+}
+
+// Validate checks the consistency of the health check settings
+// It returns an error if a value is negative or too small for the Docker daemon
+func (h HealthCheckSettings) Validate() error {
+	if h.Retries < 0 {
+		return fmt.Errorf("the number of retries must not be negative")
+	}
+	// The daemon refuses durations shorter than a millisecond
+	for name, d := range map[string]time.Duration{"interval": h.Interval, "timeout": h.Timeout, "start period": h.StartPeriod} {
+		if d != 0 && d < time.Millisecond {
+			return fmt.Errorf("the %s of the health check must be at least 1ms", name)
+		}
+	}
+	return nil
+}
+
+// HealthCheckForNode returns the health check of a node, the defaults overridden by every group that selects it
+func (config *Config) HealthCheckForNode(node int) HealthCheckSettings {
+	healthCheck := config.HealthCheck
+	for _, group := range config.groupsForNode(node) {
+		healthCheck = healthCheck.Merge(group.HealthCheck)
+	}
+	return healthCheck
+}
@@ -0,0 +1,674 @@
+package config
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Formats of the imported topology files
+const (
+	TopologyGraphML  = "graphml"
+	TopologyDOT      = "dot"
+	TopologyEdgeList = "edgelist"
+)
+
+// LinkProperties are the properties of the links from a network to another one, set by the attributes of an imported topology
+type LinkProperties struct {
+	From    int           `json:"From"`
+	To      int           `json:"To"`
+	Latency time.Duration `json:"Latency,omitempty"` // Delay added to the interfaces of the bridge nodes on the network To
+}
+
+// ImportedTopology is a topology read from a file, every vertex of the graph is a network
+type ImportedTopology struct {
+	Networks []string // Identifier of the vertex of every network
+	Matrix   [][]bool
+	Links    []LinkProperties
+}
+
+// topologyEdge is an edge of an imported graph, the attributes have lower case keys
+type topologyEdge struct {
+	source     string
+	target     string
+	directed   bool
+	attributes map[string]string
+}
+
+// topologyBuilder collects the vertices and the edges of an imported graph
+type topologyBuilder struct {
+	vertices   []string
+	attributes map[string]map[string]string
+	edges      []topologyEdge
+}
+
+// addVertex adds a vertex if it is new and merges its attributes
+func (b *topologyBuilder) addVertex(id string, attributes map[string]string) {
+	if b.attributes == nil {
+		b.attributes = map[string]map[string]string{}
+	}
+	if _, ok := b.attributes[id]; !ok {
+		b.vertices = append(b.vertices, id)
+		b.attributes[id] = map[string]string{}
+	}
+	for key, value := range attributes {
+		b.attributes[id][strings.ToLower(key)] = value
+	}
+}
+
+// addEdge adds an edge and its vertices
+func (b *topologyBuilder) addEdge(source string, target string, directed bool, attributes map[string]string) {
+	b.addVertex(source, nil)
+	b.addVertex(target, nil)
+	lower := map[string]string{}
+	for key, value := range attributes {
+		lower[strings.ToLower(key)] = value
+	}
+	b.edges = append(b.edges, topologyEdge{source, target, directed, lower})
+}
+
+// parseLatency parses a latency written as a duration (e.g. 10ms) or as a number of milliseconds
+func parseLatency(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if latency, err := time.ParseDuration(value); err == nil {
+		return latency, nil
+	}
+	milliseconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || milliseconds < 0 {
+		return 0, fmt.Errorf("invalid latency %q, it must be a duration (e.g. 10ms) or a number of milliseconds", value)
+	}
+	return time.Duration(milliseconds * float64(time.Millisecond)), nil
+}
+
+// latencyAttribute returns the latency set by the latency or the delay attribute, 0 if none is set
+func latencyAttribute(attributes map[string]string) (time.Duration, error) {
+	for _, key := range []string{"latency", "delay"} {
+		if value, ok := attributes[key]; ok && value != "" {
+			return parseLatency(value)
+		}
+	}
+	return 0, nil
+}
+
+// build numbers the networks and creates the adjacency matrix and the link properties
+// The vertices keep their identifier as number if all of them are the numbers from 0 to n-1, otherwise they are numbered in the order they appear
+// The latency of an edge is its own or, if it is not set, the one of its source vertex
+func (b *topologyBuilder) build() (*ImportedTopology, error) {
+	if len(b.vertices) == 0 {
+		return nil, fmt.Errorf("the topology has no networks")
+	}
+	numbers := map[string]int{}
+	numeric := true
+	for _, id := range b.vertices {
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 0 || n >= len(b.vertices) {
+			numeric = false
+			break
+		}
+		numbers[id] = n
+	}
+	if !numeric {
+		for i, id := range b.vertices {
+			numbers[id] = i
+		}
+	}
+	topology := &ImportedTopology{Networks: make([]string, len(b.vertices)), Matrix: make([][]bool, len(b.vertices))}
+	for id, n := range numbers {
+		topology.Networks[n] = id
+		topology.Matrix[n] = make([]bool, len(b.vertices))
+	}
+	properties := map[[2]int]time.Duration{}
+	for _, edge := range b.edges {
+		from, to := numbers[edge.source], numbers[edge.target]
+		if from == to {
+			continue
+		}
+		latency, err := latencyAttribute(edge.attributes)
+		if err != nil {
+			return nil, fmt.Errorf("error in the edge %s-%s: %v", edge.source, edge.target, err)
+		}
+		pairs := [][2]int{{from, to}}
+		if !edge.directed {
+			pairs = append(pairs, [2]int{to, from})
+		}
+		for _, pair := range pairs {
+			topology.Matrix[pair[0]][pair[1]] = true
+			pairLatency := latency
+			if pairLatency == 0 {
+				pairLatency, err = latencyAttribute(b.attributes[topology.Networks[pair[0]]])
+				if err != nil {
+					return nil, fmt.Errorf("error in the vertex %s: %v", topology.Networks[pair[0]], err)
+				}
+			}
+			if pairLatency != 0 {
+				properties[pair] = pairLatency
+			}
+		}
+	}
+	for from := range topology.Matrix {
+		for to := range topology.Matrix[from] {
+			if latency, ok := properties[[2]int{from, to}]; ok {
+				topology.Links = append(topology.Links, LinkProperties{From: from, To: to, Latency: latency})
+			}
+		}
+	}
+	return topology, nil
+}
+
+// graphMLData is the value of an attribute of a GraphML element
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// parseGraphML reads a GraphML document, the attributes are named by the attr.name of their keys
+func parseGraphML(data []byte) (*topologyBuilder, error) {
+	var document struct {
+		Keys []struct {
+			ID      string `xml:"id,attr"`
+			Name    string `xml:"attr.name,attr"`
+			For     string `xml:"for,attr"`
+			Default string `xml:"default"`
+		} `xml:"key"`
+		Graph struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				ID   string        `xml:"id,attr"`
+				Data []graphMLData `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source   string        `xml:"source,attr"`
+				Target   string        `xml:"target,attr"`
+				Directed string        `xml:"directed,attr"`
+				Data     []graphMLData `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("error decoding the GraphML file: %v", err)
+	}
+	names := map[string]string{}
+	defaults := map[string]map[string]string{"node": {}, "edge": {}}
+	for _, key := range document.Keys {
+		name := key.Name
+		if name == "" {
+			name = key.ID
+		}
+		names[key.ID] = name
+		if key.Default != "" {
+			for _, kind := range []string{"node", "edge"} {
+				if key.For == kind || key.For == "all" || key.For == "" {
+					defaults[kind][name] = strings.TrimSpace(key.Default)
+				}
+			}
+		}
+	}
+	// attributes returns the attributes of an element, starting from the defaults of its kind
+	attributes := func(kind string, data []graphMLData) map[string]string {
+		values := map[string]string{}
+		for name, value := range defaults[kind] {
+			values[name] = value
+		}
+		for _, d := range data {
+			name, ok := names[d.Key]
+			if !ok {
+				name = d.Key
+			}
+			values[name] = strings.TrimSpace(d.Value)
+		}
+		return values
+	}
+	builder := &topologyBuilder{}
+	for _, node := range document.Graph.Nodes {
+		builder.addVertex(node.ID, attributes("node", node.Data))
+	}
+	for _, edge := range document.Graph.Edges {
+		directed := document.Graph.EdgeDefault == "directed"
+		if edge.Directed != "" {
+			directed = edge.Directed == "true"
+		}
+		builder.addEdge(edge.Source, edge.Target, directed, attributes("edge", edge.Data))
+	}
+	return builder, nil
+}
+
+// dotTokens splits a DOT document into identifiers, quoted strings, edge operators and punctuation, the comments are dropped
+func dotTokens(text string) ([]string, error) {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' && lineStart(runes, i):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += 2 + len([]rune(string(runes[i+2:])[:end])) + 2
+		case r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			// The quotes are kept to tell the strings from the keywords
+			tokens = append(tokens, "\""+b.String())
+		case r == '<':
+			// HTML strings are kept as plain strings
+			depth, start := 0, i
+			for ; i < len(runes); i++ {
+				if runes[i] == '<' {
+					depth++
+				} else if runes[i] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated HTML string")
+			}
+			tokens = append(tokens, "\""+string(runes[start+1:i]))
+			i++
+		case r == '-' && i+1 < len(runes) && (runes[i+1] == '-' || runes[i+1] == '>'):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case strings.ContainsRune("{}[];,=:", r):
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.' ||
+				runes[i] == '-' && !(i+1 < len(runes) && (runes[i+1] == '-' || runes[i+1] == '>'))) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+// lineStart reports whether only spaces precede the rune at the given position on its line
+func lineStart(runes []rune, pos int) bool {
+	for i := pos - 1; i >= 0 && runes[i] != '\n'; i-- {
+		if !unicode.IsSpace(runes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// dotParser reads the statements of a DOT graph, the subgraphs are flattened
+type dotParser struct {
+	tokens       []string
+	pos          int
+	directed     bool
+	builder      *topologyBuilder
+	nodeDefaults map[string]string // Attributes of the node statement, given to the nodes created after it
+	edgeDefaults map[string]string // Attributes of the edge statement, given to the edges created after it
+}
+
+// vertex adds a node, a new one gets the default attributes first
+func (p *dotParser) vertex(id string, attributes map[string]string) {
+	if _, ok := p.builder.attributes[id]; !ok {
+		p.builder.addVertex(id, p.nodeDefaults)
+	}
+	p.builder.addVertex(id, attributes)
+}
+
+// mergeAttributes returns the attributes of a statement added to a copy of the defaults
+func mergeAttributes(defaults map[string]string, attributes map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	return merged
+}
+
+// peek returns the current token, empty at the end
+func (p *dotParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next returns the current token and moves to the next one
+func (p *dotParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// expect consumes the given token
+// It returns an error if the current token is different
+func (p *dotParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, found %q", token, got)
+	}
+	return nil
+}
+
+// keyword reports whether a token is the given keyword, the keywords are case insensitive
+func keyword(token string, name string) bool {
+	return strings.EqualFold(token, name)
+}
+
+// id returns the value of an identifier or a string
+// It returns an error if the current token is not an identifier
+func (p *dotParser) id() (string, error) {
+	token := p.next()
+	if token == "" || len(token) == 1 && strings.Contains("{}[];,=:", token) || token == "--" || token == "->" {
+		return "", fmt.Errorf("expected an identifier, found %q", token)
+	}
+	return strings.TrimPrefix(token, "\""), nil
+}
+
+// attributes reads the attribute lists that follow a statement
+// It returns an error if a list is not valid
+func (p *dotParser) attributes() (map[string]string, error) {
+	attributes := map[string]string{}
+	for p.peek() == "[" {
+		p.next()
+		for p.peek() != "]" {
+			key, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			value := "true"
+			if p.peek() == "=" {
+				p.next()
+				if value, err = p.id(); err != nil {
+					return nil, err
+				}
+			}
+			attributes[key] = value
+			if p.peek() == ";" || p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return attributes, nil
+}
+
+// statements reads the statements until the closing brace of the graph or subgraph
+// The default attributes set in a subgraph are restored at its end
+// It returns an error if a statement is not valid
+func (p *dotParser) statements() error {
+	nodeDefaults, edgeDefaults := p.nodeDefaults, p.edgeDefaults
+	defer func() { p.nodeDefaults, p.edgeDefaults = nodeDefaults, edgeDefaults }()
+	for {
+		token := p.peek()
+		switch {
+		case token == "":
+			return fmt.Errorf("missing the closing brace")
+		case token == "}":
+			p.next()
+			return nil
+		case token == ";" || token == ",":
+			p.next()
+		case keyword(token, "graph") || keyword(token, "node") || keyword(token, "edge"):
+			p.next()
+			attributes, err := p.attributes()
+			if err != nil {
+				return err
+			}
+			// The attributes of the graph are not used
+			switch {
+			case keyword(token, "node"):
+				p.nodeDefaults = mergeAttributes(p.nodeDefaults, attributes)
+			case keyword(token, "edge"):
+				p.edgeDefaults = mergeAttributes(p.edgeDefaults, attributes)
+			}
+		case keyword(token, "subgraph") || token == "{":
+			if p.next() != "{" {
+				if p.peek() != "{" {
+					if _, err := p.id(); err != nil {
+						return err
+					}
+				}
+				if err := p.expect("{"); err != nil {
+					return err
+				}
+			}
+			if err := p.statements(); err != nil {
+				return err
+			}
+			if p.peek() == "--" || p.peek() == "->" {
+				return fmt.Errorf("the edges to subgraphs are not supported")
+			}
+		default:
+			if err := p.nodeOrEdges(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nodeID reads a node identifier, the port is dropped
+// It returns an error if the identifier is not valid
+func (p *dotParser) nodeID() (string, error) {
+	id, err := p.id()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == ":" {
+		p.next()
+		if _, err := p.id(); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// nodeOrEdges reads a node statement, an edge chain or a graph attribute
+// It returns an error if the statement is not valid
+func (p *dotParser) nodeOrEdges() error {
+	ids := []string{}
+	id, err := p.nodeID()
+	if err != nil {
+		return err
+	}
+	if p.peek() == "=" {
+		// Graph attribute
+		p.next()
+		_, err := p.id()
+		return err
+	}
+	ids = append(ids, id)
+	for p.peek() == "--" || p.peek() == "->" {
+		if (p.next() == "->") != p.directed {
+			return fmt.Errorf("the edge operator doesn't match the kind of graph")
+		}
+		if p.peek() == "{" || keyword(p.peek(), "subgraph") {
+			return fmt.Errorf("the edges to subgraphs are not supported")
+		}
+		id, err := p.nodeID()
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	attributes, err := p.attributes()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 1 {
+		p.vertex(ids[0], attributes)
+		return nil
+	}
+	for _, id := range ids {
+		p.vertex(id, nil)
+	}
+	attributes = mergeAttributes(p.edgeDefaults, attributes)
+	for i := 1; i < len(ids); i++ {
+		p.builder.addEdge(ids[i-1], ids[i], p.directed, attributes)
+	}
+	return nil
+}
+
+// parseDOT reads a Graphviz DOT graph, the nodes are the networks
+func parseDOT(data []byte) (*topologyBuilder, error) {
+	tokens, err := dotTokens(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the DOT file: %v", err)
+	}
+	p := &dotParser{tokens: tokens, builder: &topologyBuilder{}}
+	if keyword(p.peek(), "strict") {
+		p.next()
+	}
+	switch kind := p.next(); {
+	case keyword(kind, "digraph"):
+		p.directed = true
+	case !keyword(kind, "graph"):
+		return nil, fmt.Errorf("error reading the DOT file: expected graph or digraph, found %q", kind)
+	}
+	if p.peek() != "{" {
+		if _, err := p.id(); err != nil {
+			return nil, fmt.Errorf("error reading the DOT file: %v", err)
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, fmt.Errorf("error reading the DOT file: %v", err)
+	}
+	if err := p.statements(); err != nil {
+		return nil, fmt.Errorf("error reading the DOT file: %v", err)
+	}
+	return p.builder, nil
+}
+
+// parseEdgeList reads an edge list, one edge per line written as "a b", "a -- b" or "a -> b" for a one-way link
+// The edge is followed by key=value attributes, a bare value is the latency; a line with a single vertex adds a network without links
+func parseEdgeList(data []byte) (*topologyBuilder, error) {
+	builder := &topologyBuilder{}
+	for number, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool { return unicode.IsSpace(r) || r == ',' || r == ';' })
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			builder.addVertex(fields[0], nil)
+			continue
+		}
+		directed := false
+		if fields[1] == "->" || fields[1] == "--" {
+			directed = fields[1] == "->"
+			fields = append(fields[:1], fields[2:]...)
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("error in the line %d of the edge list: missing the target", number+1)
+		}
+		attributes := map[string]string{}
+		for _, field := range fields[2:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				key, value = "latency", field
+			}
+			attributes[key] = value
+		}
+		builder.addEdge(fields[0], fields[1], directed, attributes)
+	}
+	return builder, nil
+}
+
+// TopologyFormat returns the format of a topology file from its extension or, if it is not known, from its content
+func TopologyFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".graphml", ".xml":
+		return TopologyGraphML
+	case ".dot", ".gv":
+		return TopologyDOT
+	case ".txt", ".edges", ".edgelist", ".csv", ".tsv":
+		return TopologyEdgeList
+	}
+	content := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(content, "<"):
+		return TopologyGraphML
+	case strings.Contains(content, "{") && strings.Contains(strings.ToLower(content), "graph"):
+		return TopologyDOT
+	}
+	return TopologyEdgeList
+}
+
+// ImportTopology reads a topology from a GraphML, DOT or edge list file, the format is guessed from the extension or the content
+// It returns an error if the file can't be read or is not valid
+func ImportTopology(path string) (*ImportedTopology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the topology file: %v", err)
+	}
+	var builder *topologyBuilder
+	switch TopologyFormat(path, data) {
+	case TopologyGraphML:
+		builder, err = parseGraphML(data)
+	case TopologyDOT:
+		builder, err = parseDOT(data)
+	default:
+		builder, err = parseEdgeList(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	topology, err := builder.build()
+	if err != nil {
+		return nil, fmt.Errorf("error in the topology file %s: %v", path, err)
+	}
+	return topology, nil
+}
+
+// ImportTopologyFile replaces the number of networks and the adjacency matrix with the ones of the topology file, and sets the properties of the links
+// It returns an error if the topology file can't be imported
+func (c *Config) ImportTopologyFile() error {
+	topology, err := ImportTopology(*c.TopologyFile)
+	if err != nil {
+		return err
+	}
+	numNetworks := len(topology.Matrix)
+	c.NumNetworks = &numNetworks
+	c.NetMatrix = topology.Matrix
+	c.LinkProperties = topology.Links
+	return nil
+}
+
+// LinkPropertiesOf returns the properties of the links from a network to another one and false if they have none
+func (c *Config) LinkPropertiesOf(from int, to int) (LinkProperties, bool) {
+	for _, properties := range c.LinkProperties {
+		if properties.From == from && properties.To == to {
+			return properties, true
+		}
+	}
+	return LinkProperties{}, false
+}
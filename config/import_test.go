@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestImportTopology(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		networks []string
+		matrix   [][]bool
+		links    []LinkProperties
+	}{
+		{
+			name: "graphml undirected",
+			file: "mesh.graphml",
+			content: `<graphml>
+  <key id="d0" for="edge" attr.name="latency"/>
+  <graph edgedefault="undirected">
+    <node id="a"/><node id="b"/><node id="c"/>
+    <edge source="a" target="b"><data key="d0">10ms</data></edge>
+    <edge source="b" target="c"/>
+  </graph>
+</graphml>`,
+			networks: []string{"a", "b", "c"},
+			matrix:   [][]bool{{false, true, false}, {true, false, true}, {false, true, false}},
+			links:    []LinkProperties{{From: 0, To: 1, Latency: 10 * time.Millisecond}, {From: 1, To: 0, Latency: 10 * time.Millisecond}},
+		},
+		{
+			name: "graphml directed with a key default and an undirected edge",
+			file: "mesh.xml",
+			content: `<graphml>
+  <key id="delay" for="edge"><default>2</default></key>
+  <graph edgedefault="directed">
+    <node id="1"/><node id="0"/>
+    <edge source="0" target="1"/>
+    <edge source="1" target="0" directed="false"><data key="delay">500us</data></edge>
+  </graph>
+</graphml>`,
+			networks: []string{"0", "1"},
+			matrix:   [][]bool{{false, true}, {true, false}},
+			links:    []LinkProperties{{From: 0, To: 1, Latency: 500 * time.Microsecond}, {From: 1, To: 0, Latency: 500 * time.Microsecond}},
+		},
+		{
+			name: "dot identifiers 0 to n-1 are kept",
+			file: "mesh.dot",
+			content: `graph mesh {
+  2 -- 0 -- 1; // a chain
+  1 [latency=3]
+}`,
+			networks: []string{"0", "1", "2"},
+			matrix:   [][]bool{{false, true, true}, {true, false, false}, {true, false, false}},
+			links:    []LinkProperties{{From: 1, To: 0, Latency: 3 * time.Millisecond}},
+		},
+		{
+			name:     "dot other identifiers are numbered in order of appearance",
+			file:     "mesh.gv",
+			content:  `graph { 1 -- 2; 2 -- 3 }`,
+			networks: []string{"1", "2", "3"},
+			matrix:   [][]bool{{false, true, false}, {true, false, true}, {false, true, false}},
+		},
+		{
+			name: "dot digraph with node and edge defaults",
+			file: "mesh.dot",
+			content: `strict digraph "mesh" {
+  a -> b
+  edge [latency=5ms]
+  b -> c
+  node [delay=7]
+  c -> d
+  edge [latency=""]
+  d -> a [weight=2]
+}`,
+			networks: []string{"a", "b", "c", "d"},
+			matrix:   [][]bool{{false, true, false, false}, {false, false, true, false}, {false, false, false, true}, {true, false, false, false}},
+			links:    []LinkProperties{{From: 1, To: 2, Latency: 5 * time.Millisecond}, {From: 2, To: 3, Latency: 5 * time.Millisecond}, {From: 3, To: 0, Latency: 7 * time.Millisecond}},
+		},
+		{
+			name: "dot defaults of a subgraph stay in the subgraph",
+			file: "mesh.dot",
+			content: `graph {
+  subgraph cluster { edge [latency=1ms]; a -- b }
+  b -- c
+}`,
+			networks: []string{"a", "b", "c"},
+			matrix:   [][]bool{{false, true, false}, {true, false, true}, {false, true, false}},
+			links:    []LinkProperties{{From: 0, To: 1, Latency: time.Millisecond}, {From: 1, To: 0, Latency: time.Millisecond}},
+		},
+		{
+			name: "edge list",
+			file: "mesh.txt",
+			content: `# networks
+0 1 20ms
+1 -> 2 latency=1.5
+2, 0
+3
+`,
+			networks: []string{"0", "1", "2", "3"},
+			matrix:   [][]bool{{false, true, true, false}, {true, false, true, false}, {true, false, false, false}, {false, false, false, false}},
+			links:    []LinkProperties{{From: 0, To: 1, Latency: 20 * time.Millisecond}, {From: 1, To: 0, Latency: 20 * time.Millisecond}, {From: 1, To: 2, Latency: 1500 * time.Microsecond}},
+		},
+		{
+			name:     "format guessed from the content",
+			file:     "topology",
+			content:  `digraph { x -> y }`,
+			networks: []string{"x", "y"},
+			matrix:   [][]bool{{false, true}, {false, false}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			topology, err := ImportTopology(path)
+			if err != nil {
+				t.Fatalf("ImportTopology() error = %v", err)
+			}
+			if !reflect.DeepEqual(topology.Networks, test.networks) {
+				t.Errorf("Networks = %v, want %v", topology.Networks, test.networks)
+			}
+			if !reflect.DeepEqual(topology.Matrix, test.matrix) {
+				t.Errorf("Matrix = %v, want %v", topology.Matrix, test.matrix)
+			}
+			if len(topology.Links) != 0 || len(test.links) != 0 {
+				if !reflect.DeepEqual(topology.Links, test.links) {
+					t.Errorf("Links = %v, want %v", topology.Links, test.links)
+				}
+			}
+		})
+	}
+}
+
+func TestImportTopologyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "empty edge list", file: "mesh.txt", content: "# nothing\n"},
+		{name: "invalid latency", file: "mesh.txt", content: "0 1 latency=fast\n"},
+		{name: "missing target", file: "mesh.txt", content: "0 ->\n"},
+		{name: "dot edge operator of the other kind", file: "mesh.dot", content: "graph { a -> b }"},
+		{name: "dot missing closing brace", file: "mesh.dot", content: "graph { a -- b"},
+		{name: "dot edge to a subgraph", file: "mesh.dot", content: "graph { a -- { b c } }"},
+		{name: "invalid graphml", file: "mesh.graphml", content: "<graphml><graph>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ImportTopology(path); err == nil {
+				t.Errorf("ImportTopology() error = nil, want an error")
+			}
+		})
+	}
+}
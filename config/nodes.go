@@ -0,0 +1,63 @@
+package config
+
+import "strings"
+
+// DefaultNodeCommand keeps the nodes running when no command is set
+var DefaultNodeCommand = []string{"tail", "-f", "/dev/null"}
+
+// DefaultShell is the shell opened in the nodes when none is set
+const DefaultShell = "/bin/sh"
+
+// NodeSettings describes the process run by the nodes
+type NodeSettings struct {
+	Command []string `yaml:"Command,omitempty"` // Command of the nodes, by default one that keeps them running
+	Env     []string `yaml:"Env,omitempty"`     // Environment variables of the nodes, e.g. KEY=value
+	Shell   string   `yaml:"Shell,omitempty"`   // Shell opened by the shell command, /bin/sh by default
+	User    string   `yaml:"User,omitempty"`    // User of the shell, by default the user of the nodes
+	WorkDir string   `yaml:"WorkDir,omitempty"` // Working directory of the shell, by default the one of the image
+}
+
+// ImageForNode returns the image of a node, the one of the last group that selects it and sets one or the main image
+func (config *Config) ImageForNode(node int) string {
+	image := *config.ImageName
+	for _, group := range config.groupsForNode(node) {
+		if group.Image != "" {
+			image = group.Image
+		}
+	}
+	return image
+}
+
+// CommandForNode returns the command of a node, the one of the last group that selects it and sets one, the default one or the command that keeps the node running
+func (config *Config) CommandForNode(node int) []string {
+	command := DefaultNodeCommand
+	if len(config.Node.Command) > 0 {
+		command = config.Node.Command
+	}
+	for _, group := range config.groupsForNode(node) {
+		if len(group.Command) > 0 {
+			command = group.Command
+		}
+	}
+	return command
+}
+
+// EnvForNode returns the environment variables of a node, the defaults overridden by every group that selects it
+func (config *Config) EnvForNode(node int) []string {
+	env := append([]string{}, config.Node.Env...)
+	for _, group := range config.groupsForNode(node) {
+		for _, variable := range group.Env {
+			key, _, _ := strings.Cut(variable, "=")
+			replaced := false
+			for i, existing := range env {
+				if existingKey, _, _ := strings.Cut(existing, "="); existingKey == key {
+					env[i], replaced = variable, true
+				}
+			}
+			if !replaced {
+				env = append(env, variable)
+			}
+		}
+	}
+	return env
+}
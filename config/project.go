@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Defaults of the subnets allocated to the networks
+const (
+	DefaultSubnetPool = "10.200.0.0/16" // Range where the subnets of the networks are allocated
+	DefaultSubnetSize = 24              // Prefix length of the subnet of every network
+)
+
+// ProjectSeparator joins the project name to the image and network names
+// A Docker image name can't contain it, the network names are checked not to and the project names have no '_', so a mesh name has a single reading
+const ProjectSeparator = "_-"
+
+var projectNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*$`)
+
+// ProjectSettings isolates a virtual environment from the other ones running on the same host
+type ProjectSettings struct {
+	Name       string `yaml:"Name,omitempty"`       // Name of the environment, it prefixes its containers, networks and state
+	SubnetPool string `yaml:"SubnetPool,omitempty"` // Range where the subnets of the networks are allocated, e.g. 10.200.0.0/16
+	SubnetSize int    `yaml:"SubnetSize,omitempty"` // Prefix length of the subnet of every network, e.g. 24
+}
+
+// Validate checks the consistency of the project settings
+// It returns an error if the name can't prefix a container name or the subnet pool is not valid
+func (s ProjectSettings) Validate() error {
+	if s.Name != "" && !projectNameRegexp.MatchString(s.Name) {
+		return fmt.Errorf("invalid project name %q, it must start with a letter or a digit followed by letters, digits, '.' or '-'", s.Name)
+	}
+	pool, err := s.Pool()
+	if err != nil {
+		return err
+	}
+	size := s.Size()
+	if size < pool.Bits() || size > 30 {
+		return fmt.Errorf("invalid subnet size /%d, it must be between /%d and /30", size, pool.Bits())
+	}
+	return nil
+}
+
+// Pool returns the range where the subnets are allocated, the default one if it is not set
+// It returns an error if the range is not a valid IPv4 prefix
+func (s ProjectSettings) Pool() (netip.Prefix, error) {
+	value := s.SubnetPool
+	if value == "" {
+		value = DefaultSubnetPool
+	}
+	pool, err := netip.ParsePrefix(value)
+	if err != nil || !pool.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("invalid subnet pool %q, it must be an IPv4 range like %s", value, DefaultSubnetPool)
+	}
+	return pool.Masked(), nil
+}
+
+// Size returns the prefix length of the subnets, the default one if it is not set
+func (s ProjectSettings) Size() int {
+	if s.SubnetSize == 0 {
+		return DefaultSubnetSize
+	}
+	return s.SubnetSize
+}
+
+// ValidateNames checks that the image and network names can't be mistaken for a name prefixed by a project
+// It returns an error if one of them contains the project separator
+func (config *Config) ValidateNames() error {
+	for _, name := range []string{*config.ImageName, *config.NetworkName} {
+		if strings.Contains(name, ProjectSeparator) {
+			return fmt.Errorf("invalid name %q, it can't contain %q that separates the project name", name, ProjectSeparator)
+		}
+	}
+	return nil
+}
+
+// MeshName returns the name the containers and the state of the environment derive from, the image name prefixed by the project name if one is set
+func (config *Config) MeshName() string {
+	if config.Project.Name == "" {
+		return *config.ImageName
+	}
+	return config.Project.Name + ProjectSeparator + *config.ImageName
+}
+
+// NetworkPrefix returns the prefix of the names of the networks, the network name prefixed by the project name if one is set
+func (config *Config) NetworkPrefix() string {
+	if config.Project.Name == "" {
+		return *config.NetworkName
+	}
+	return config.Project.Name + ProjectSeparator + *config.NetworkName
+}
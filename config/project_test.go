@@ -0,0 +1,86 @@
+package config
+
+import "testing"
+
+// namedConfig returns a configuration with the given project, image and network names
+func namedConfig(project string, image string, network string) *Config {
+	return &Config{ImageName: &image, NetworkName: &network, Project: ProjectSettings{Name: project}}
+}
+
+func TestMeshNamesDontCollide(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Config
+	}{
+		{name: "project and image vs default image", a: namedConfig("test", "name", "network"), b: namedConfig("", "test_name", "test_network")},
+		{name: "project and image vs image with a dash", a: namedConfig("test", "name", "network"), b: namedConfig("", "test-name", "test-network")},
+		{name: "two projects", a: namedConfig("a", "b_c", "n_m"), b: namedConfig("a-b", "c", "m")},
+		{name: "project vs no project", a: namedConfig("ci", "erlang", "net"), b: namedConfig("", "erlang", "net")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if a, b := test.a.MeshName(), test.b.MeshName(); a == b {
+				t.Errorf("MeshName() = %q for both", a)
+			}
+			if a, b := test.a.NetworkPrefix(), test.b.NetworkPrefix(); a == b {
+				t.Errorf("NetworkPrefix() = %q for both", a)
+			}
+		})
+	}
+}
+
+func TestMeshName(t *testing.T) {
+	if got := namedConfig("", "erlang", "net").MeshName(); got != "erlang" {
+		t.Errorf("MeshName() = %q, want erlang", got)
+	}
+	if got := namedConfig("ci-42", "erlang", "net").MeshName(); got != "ci-42_-erlang" {
+		t.Errorf("MeshName() = %q, want ci-42_-erlang", got)
+	}
+	if got := namedConfig("ci-42", "erlang", "net").NetworkPrefix(); got != "ci-42_-net" {
+		t.Errorf("NetworkPrefix() = %q, want ci-42_-net", got)
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{name: "default names", config: namedConfig("", "test_name", "test_network")},
+		{name: "project", config: namedConfig("ci", "erlang", "net")},
+		{name: "separator in the network name", config: namedConfig("", "erlang", "ci_-net"), wantErr: true},
+		{name: "separator in the image name", config: namedConfig("", "ci_-erlang", "net"), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.ValidateNames(); (err != nil) != test.wantErr {
+				t.Errorf("ValidateNames() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ProjectSettings
+		wantErr  bool
+	}{
+		{name: "defaults", settings: ProjectSettings{}},
+		{name: "name with dots and dashes", settings: ProjectSettings{Name: "ci-42.a"}},
+		{name: "name with an underscore", settings: ProjectSettings{Name: "ci_42"}, wantErr: true},
+		{name: "name starting with a dash", settings: ProjectSettings{Name: "-ci"}, wantErr: true},
+		{name: "invalid pool", settings: ProjectSettings{SubnetPool: "10.0.0.0"}, wantErr: true},
+		{name: "IPv6 pool", settings: ProjectSettings{SubnetPool: "fd00::/64"}, wantErr: true},
+		{name: "size larger than the pool", settings: ProjectSettings{SubnetPool: "10.0.0.0/24", SubnetSize: 16}, wantErr: true},
+		{name: "size too small", settings: ProjectSettings{SubnetSize: 31}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.settings.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
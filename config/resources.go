@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+// UlimitSettings describes a single ulimit applied to the nodes
+type UlimitSettings struct {
+	Name string `yaml:"Name" json:"Name"`
+	Soft int64  `yaml:"Soft" json:"Soft"`
+	Hard int64  `yaml:"Hard" json:"Hard"`
+}
+
+// ResourceSettings describes the resource limits of a node, a zero value means that the limit is not set
+type ResourceSettings struct {
+	CPUQuota    int64            `yaml:"CPUQuota,omitempty" json:"CPUQuota,omitempty"`
+	CPUPeriod   int64            `yaml:"CPUPeriod,omitempty" json:"CPUPeriod,omitempty"`
+	CPUShares   int64            `yaml:"CPUShares,omitempty" json:"CPUShares,omitempty"`
+	CPUSetCPUs  string           `yaml:"CPUSetCPUs,omitempty" json:"CPUSetCPUs,omitempty"`
+	Memory      string           `yaml:"Memory,omitempty" json:"Memory,omitempty"`         // e.g. 256m, 1g
+	MemorySwap  string           `yaml:"MemorySwap,omitempty" json:"MemorySwap,omitempty"` // memory + swap, -1 for unlimited swap
+	PidsLimit   int64            `yaml:"PidsLimit,omitempty" json:"PidsLimit,omitempty"`
+	BlkioWeight uint16           `yaml:"BlkioWeight,omitempty" json:"BlkioWeight,omitempty"` // between 10 and 1000
+	Ulimits     []UlimitSettings `yaml:"Ulimits,omitempty" json:"Ulimits,omitempty"`
+}
+
+// NodeGroup selects a set of nodes and overrides the default settings for them
+type NodeGroup struct {
+	Name        string              `yaml:"Name,omitempty"`
+	Nodes       string              `yaml:"Nodes"` // e.g. "3", "0-4", "0,2,5-7"
+	Resources   ResourceSettings    `yaml:"Resources,omitempty"`
+	HealthCheck HealthCheckSettings `yaml:"HealthCheck,omitempty"`
+	DependsOn   []string            `yaml:"DependsOn,omitempty"` // Groups or node selections that must be ready before these nodes start
+	Image       string              `yaml:"Image,omitempty"`     // Image of these nodes instead of the main one, pulled if it is not available locally
+	Command     []string            `yaml:"Command,omitempty"`   // Command of these nodes instead of the default one
+	Env         []string            `yaml:"Env,omitempty"`       // Environment variables added to the default ones, e.g. KEY=value
+}
+
+// Merge returns the settings obtained by overriding the receiver with the non zero values of other
+func (r ResourceSettings) Merge(other ResourceSettings) ResourceSettings {
+	if other.CPUQuota != 0 {
+		r.CPUQuota = other.CPUQuota
+	}
+	if other.CPUPeriod != 0 {
+		r.CPUPeriod = other.CPUPeriod
+	}
+	if other.CPUShares != 0 {
+		r.CPUShares = other.CPUShares
+	}
+	if other.CPUSetCPUs != "" {
+		r.CPUSetCPUs = other.CPUSetCPUs
+	}
+	if other.Memory != "" {
+		r.Memory = other.Memory
+	}
+	if other.MemorySwap != "" {
+		r.MemorySwap = other.MemorySwap
+	}
+	if other.PidsLimit != 0 {
+		r.PidsLimit = other.PidsLimit
+	}
+	if other.BlkioWeight != 0 {
+		r.BlkioWeight = other.BlkioWeight
+	}
+	if len(other.Ulimits) > 0 {
+		// Ulimits are overridden by name
+		ulimits := append([]UlimitSettings{}, r.Ulimits...)
+		for _, u := range other.Ulimits {
+			replaced := false
+			for i := range ulimits {
+				if ulimits[i].Name == u.Name {
+					ulimits[i] = u
+					replaced = true
+				}
+			}
+			if !replaced {
+				ulimits = append(ulimits, u)
+			}
+		}
+		r.Ulimits = ulimits
+	}
+	return r
+}
+
+// MemoryBytes returns the memory limit and the memory+swap limit in bytes
+// It returns an error if one of the sizes is not valid
+func (r ResourceSettings) MemoryBytes() (int64, int64, error) {
+	var memory, swap int64
+	var err error
+	if r.Memory != "" {
+		memory, err = units.RAMInBytes(r.Memory)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid memory limit %q: %v", r.Memory, err)
+		}
+	}
+	if r.MemorySwap == "-1" {
+		swap = -1
+	} else if r.MemorySwap != "" {
+		swap, err = units.RAMInBytes(r.MemorySwap)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid memory swap limit %q: %v", r.MemorySwap, err)
+		}
+	}
+	return memory, swap, nil
+}
+
+// Validate checks the consistency of the resource settings
+// It returns an error if a size is not valid or a value is out of range
+func (r ResourceSettings) Validate() error {
+	return r.validate(false)
+}
+
+// ValidateUpdate checks the settings of a runtime update, which only changes the limits that are set
+// The swap limit can be set without the memory limit, it is then compared to the current one by the caller
+// It returns an error if a size is not valid or a value is out of range
+func (r ResourceSettings) ValidateUpdate() error {
+	return r.validate(true)
+}
+
+// validate checks the resource settings, the swap limit needs a memory limit unless the settings are a partial update
+func (r ResourceSettings) validate(partial bool) error {
+	memory, swap, err := r.MemoryBytes()
+	if err != nil {
+		return err
+	}
+	if swap > 0 && (memory == 0 && !partial || swap < memory) {
+		return fmt.Errorf("the memory swap limit must be greater than or equal to the memory limit")
+	}
+	if r.CPUQuota < 0 || r.CPUPeriod < 0 || r.CPUShares < 0 {
+		return fmt.Errorf("the cpu quota, period and shares must not be negative")
+	}
+	if r.BlkioWeight != 0 && (r.BlkioWeight < 10 || r.BlkioWeight > 1000) {
+		return fmt.Errorf("the block IO weight must be between 10 and 1000")
+	}
+	for _, u := range r.Ulimits {
+		if u.Name == "" {
+			return fmt.Errorf("ulimit without a name")
+		}
+		if u.Soft > u.Hard {
+			return fmt.Errorf("the soft limit of the ulimit %s is greater than the hard limit", u.Name)
+		}
+	}
+	return nil
+}
+
+// ParseNodeSelection parses a node selection like "0-4,7" given the total number of nodes
+// It returns the sorted list of selected nodes and an error if the selection is not valid
+func ParseNodeSelection(selection string, numNodes int) ([]int, error) {
+	seen := map[int]bool{}
+	var nodes []int
+	for _, part := range strings.Split(selection, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			first, last = part[:i], part[i+1:]
+		}
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selection %q", part)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(last))
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selection %q", part)
+		}
+		if from > to || from < 0 || to >= numNodes {
+			return nil, fmt.Errorf("node selection %q out of range, the nodes are numbered from 0 to %d", part, numNodes-1)
+		}
+		for n := from; n <= to; n++ {
+			if !seen[n] {
+				seen[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("empty node selection")
+	}
+	sort.Ints(nodes)
+	return nodes, nil
+}
+
+// TotalNodes returns the number of nodes of the virtual environment
+func (config *Config) TotalNodes() int {
+	return *config.NumContainers * *config.NumNetworks
+}
+
+// groupsForNode returns the node groups that select the node, in the order they are defined
+func (config *Config) groupsForNode(node int) []NodeGroup {
+	var groups []NodeGroup
+	for _, group := range config.NodeGroups {
+		nodes, err := ParseNodeSelection(group.Nodes, config.TotalNodes())
+		if err != nil {
+			continue
+		}
+		i := sort.SearchInts(nodes, node)
+		if i < len(nodes) && nodes[i] == node {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// ResourcesForNode returns the resource settings of a node, the defaults overridden by every group that selects it
+func (config *Config) ResourcesForNode(node int) ResourceSettings {
+	resources := config.Resources
+	for _, group := range config.groupsForNode(node) {
+		resources = resources.Merge(group.Resources)
+	}
+	return resources
+}
+
+// ParseNodes parses a selection of the nodes of the virtual environment, e.g. "0-4,7"
+// It returns the sorted list of selected nodes and an error if the selection is not valid
+func (config *Config) ParseNodes(selection string) ([]int, error) {
+	return ParseNodeSelection(selection, config.TotalNodes())
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestResourceSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      ResourceSettings
+		wantErr       bool
+		wantUpdateErr bool
+	}{
+		{name: "empty", settings: ResourceSettings{}},
+		{name: "memory and swap", settings: ResourceSettings{Memory: "256m", MemorySwap: "512m"}},
+		{name: "unlimited swap", settings: ResourceSettings{Memory: "256m", MemorySwap: "-1"}},
+		{name: "swap only", settings: ResourceSettings{MemorySwap: "512m"}, wantErr: true},
+		{name: "swap lower than the memory", settings: ResourceSettings{Memory: "1g", MemorySwap: "512m"}, wantErr: true, wantUpdateErr: true},
+		{name: "invalid swap", settings: ResourceSettings{MemorySwap: "lots"}, wantErr: true, wantUpdateErr: true},
+		{name: "negative cpu quota", settings: ResourceSettings{CPUQuota: -1}, wantErr: true, wantUpdateErr: true},
+		{name: "block IO weight out of range", settings: ResourceSettings{BlkioWeight: 5}, wantErr: true, wantUpdateErr: true},
+		{name: "soft ulimit greater than the hard one", settings: ResourceSettings{Ulimits: []UlimitSettings{{Name: "nofile", Soft: 2, Hard: 1}}}, wantErr: true, wantUpdateErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.settings.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err := test.settings.ValidateUpdate(); (err != nil) != test.wantUpdateErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, test.wantUpdateErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScenarioActions are the actions that a scenario step can perform
+var ScenarioActions = []string{"stop", "start", "pause", "unpause", "kill", "restart", "latency", "partition", "link-drop", "heal", "exec"}
+
+// ScenarioStep is a timed action of a scenario
+type ScenarioStep struct {
+	At       time.Duration `yaml:"At"`                 // Time of the step from the start of the scenario
+	Action   string        `yaml:"Action"`             // One of ScenarioActions
+	Target   string        `yaml:"Target,omitempty"`   // Nodes (e.g. 0-4), networks (e.g. 0|1), link (e.g. 3:1 for node 3 in network 1) or all
+	Signal   string        `yaml:"Signal,omitempty"`   // Signal of the kill action
+	Delay    time.Duration `yaml:"Delay,omitempty"`    // Downtime of the restart action
+	Latency  time.Duration `yaml:"Latency,omitempty"`  // Delay of the latency action, 0 to remove it
+	Command  string        `yaml:"Command,omitempty"`  // Shell command of the exec action
+	Timeout  time.Duration `yaml:"Timeout,omitempty"`  // Maximum duration of the exec action
+	ExitCode *int          `yaml:"ExitCode,omitempty"` // Exit code expected from the exec action
+	Output   string        `yaml:"Output,omitempty"`   // Text expected in the output of the exec action
+}
+
+// Scenario is a list of timed steps run against the virtual environment
+type Scenario struct {
+	Name  string         `yaml:"Name,omitempty"`
+	Steps []ScenarioStep `yaml:"Steps"`
+}
+
+// LoadScenario reads a scenario file and sorts its steps by time
+// It returns an error if the file can't be read or a step is not valid
+func LoadScenario(path string) (*Scenario, error) {
+	filename, _ := filepath.Abs(path)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the scenario file: %v", err)
+	}
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("error during the unmarshal of the scenario file: %v", err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = filepath.Base(path)
+	}
+	for i, step := range scenario.Steps {
+		known := false
+		for _, action := range ScenarioActions {
+			known = known || action == step.Action
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown action %q in the step %d, the actions are %v", step.Action, i+1, ScenarioActions)
+		}
+		if step.At < 0 {
+			return nil, fmt.Errorf("negative time in the step %d", i+1)
+		}
+		if step.Action == "exec" && step.Command == "" {
+			return nil, fmt.Errorf("missing the command of the exec step %d", i+1)
+		}
+		if step.Target == "" && step.Action != "heal" {
+			return nil, fmt.Errorf("missing the target of the step %d", i+1)
+		}
+	}
+	sort.SliceStable(scenario.Steps, func(i, j int) bool {
+		return scenario.Steps[i].At < scenario.Steps[j].At
+	})
+	return &scenario, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultCapabilities are the only capabilities granted to the nodes by default,
+// NET_ADMIN is needed to change the routes and the traffic control (netem) rules of the nodes
+var DefaultCapabilities = []string{"NET_ADMIN"}
+
+var capabilityRegexp = regexp.MustCompile(`^(CAP_)?[A-Z_]+$`)
+
+// SecuritySettings describes the security profile of the nodes
+type SecuritySettings struct {
+	Privileged      bool     `yaml:"Privileged,omitempty"`      // Run the nodes in privileged mode, the other settings are ignored
+	CapAdd          []string `yaml:"CapAdd,omitempty"`          // Capabilities added to the default ones
+	CapDrop         []string `yaml:"CapDrop,omitempty"`         // Default capabilities to drop
+	SeccompProfile  string   `yaml:"SeccompProfile,omitempty"`  // Path to a seccomp profile or "unconfined"
+	AppArmorProfile string   `yaml:"AppArmorProfile,omitempty"` // Name of a loaded apparmor profile or "unconfined"
+	NoNewPrivileges bool     `yaml:"NoNewPrivileges,omitempty"` // Prevent the processes from gaining new privileges
+	ReadOnlyRootfs  bool     `yaml:"ReadOnlyRootfs,omitempty"`  // Mount the root filesystem as read only
+	User            string   `yaml:"User,omitempty"`            // User (and group) running the processes, e.g. 1000:1000
+}
+
+// Validate checks the consistency of the security settings
+// It returns an error if a capability name is not valid or the seccomp profile can't be read
+func (s SecuritySettings) Validate() error {
+	for _, capability := range append(append([]string{}, s.CapAdd...), s.CapDrop...) {
+		if !capabilityRegexp.MatchString(strings.ToUpper(capability)) {
+			return fmt.Errorf("invalid capability %q", capability)
+		}
+	}
+	if s.SeccompProfile != "" && s.SeccompProfile != "unconfined" {
+		if _, err := os.Stat(s.SeccompProfile); err != nil {
+			return fmt.Errorf("error reading the seccomp profile: %v", err)
+		}
+	}
+	return nil
+}
+
+// Capabilities returns the capabilities granted to the nodes, the default ones minus the dropped ones plus the added ones
+func (s SecuritySettings) Capabilities() []string {
+	dropped := map[string]bool{}
+	for _, capability := range s.CapDrop {
+		dropped[normalizeCapability(capability)] = true
+	}
+	var capabilities []string
+	seen := map[string]bool{}
+	for _, capability := range DefaultCapabilities {
+		if !dropped[capability] {
+			seen[capability] = true
+			capabilities = append(capabilities, capability)
+		}
+	}
+	for _, capability := range s.CapAdd {
+		capability = normalizeCapability(capability)
+		if !seen[capability] {
+			seen[capability] = true
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// normalizeCapability returns the capability name in upper case without the CAP_ prefix
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+}
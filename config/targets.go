@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NetworkNodes returns the nodes created in a network
+// It returns an error if the network doesn't exist
+func (config *Config) NetworkNodes(network int) ([]int, error) {
+	if network < 0 || network >= *config.NumNetworks {
+		return nil, fmt.Errorf("network %d out of range, the networks are numbered from 0 to %d", network, *config.NumNetworks-1)
+	}
+	nodes := make([]int, *config.NumContainers)
+	for i := range nodes {
+		nodes[i] = network**config.NumContainers + i
+	}
+	return nodes, nil
+}
+
+// ResolveTarget returns the nodes selected by a target: "all", a network ("net:1"), the name of a node group or a node selection ("0-4,7")
+// It returns an error if the target doesn't select any node
+func (config *Config) ResolveTarget(target string) ([]int, error) {
+	target = strings.TrimSpace(target)
+	if target == "all" {
+		return ParseNodeSelection(fmt.Sprintf("0-%d", config.TotalNodes()-1), config.TotalNodes())
+	}
+	if network, ok := strings.CutPrefix(target, "net:"); ok {
+		n, err := strconv.Atoi(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", network)
+		}
+		return config.NetworkNodes(n)
+	}
+	return config.resolveDependency(target)
+}
+
+// FormatNodeSelection writes a sorted list of nodes in the compact form parsed by ParseNodeSelection, e.g. "0-4,7"
+func FormatNodeSelection(nodes []int) string {
+	var parts []string
+	for i := 0; i < len(nodes); {
+		j := i
+		for j+1 < len(nodes) && nodes[j+1] == nodes[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(nodes[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", nodes[i], nodes[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Topologies are the names of the generated topologies, in the order they are offered by the matrix editor
+var Topologies = []string{"empty", "line", "ring", "star", "tree", "mesh"}
+
+// GenerateTopology returns the symmetric adjacency matrix of a generated topology given its name and the number of networks
+// It returns an error if the topology is not known
+func GenerateTopology(name string, numNetworks int) ([][]bool, error) {
+	matrix := make([][]bool, numNetworks)
+	for i := range matrix {
+		matrix[i] = make([]bool, numNetworks)
+	}
+	link := func(i int, j int) {
+		if i != j {
+			matrix[i][j] = true
+			matrix[j][i] = true
+		}
+	}
+	switch name {
+	case "empty":
+	case "line":
+		for i := 1; i < numNetworks; i++ {
+			link(i-1, i)
+		}
+	case "ring":
+		for i := 1; i < numNetworks; i++ {
+			link(i-1, i)
+		}
+		if numNetworks > 2 {
+			link(numNetworks-1, 0)
+		}
+	case "star":
+		for i := 1; i < numNetworks; i++ {
+			link(0, i)
+		}
+	case "tree":
+		// Binary tree rooted in the network 0
+		for i := 1; i < numNetworks; i++ {
+			link((i-1)/2, i)
+		}
+	case "mesh":
+		for i := 0; i < numNetworks; i++ {
+			for j := i + 1; j < numNetworks; j++ {
+				link(i, j)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown topology %q, it must be one of %v", name, Topologies)
+	}
+	return matrix, nil
+}
+
+// mappingValue returns the value of a key of a yaml mapping, adding the key if it is missing
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+	valueNode := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, keyNode, valueNode)
+	return valueNode
+}
+
+// SaveNetMatrix writes the adjacency matrix and the number of networks in the NetworkSettings section of a yaml file
+// The other settings and the comments of an existing file are kept, the file is created if it doesn't exist
+// It returns an error if the file can't be read, parsed or written
+func SaveNetMatrix(path string, matrix [][]bool) error {
+	var document yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading the yaml file: %v", err)
+	}
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("error during the unmarshal of the yaml file: %v", err)
+		}
+	}
+	if len(document.Content) == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("the yaml file %s is not a mapping", path)
+	}
+	settings := mappingValue(root, "NetworkSettings")
+	if settings.Kind != yaml.MappingNode {
+		*settings = yaml.Node{Kind: yaml.MappingNode}
+	}
+	if err := mappingValue(settings, "NumNetworks").Encode(len(matrix)); err != nil {
+		return err
+	}
+	matrixNode := mappingValue(settings, "NetMatrix")
+	if err := matrixNode.Encode(matrix); err != nil {
+		return err
+	}
+	// One row per line, as written by hand
+	for _, row := range matrixNode.Content {
+		row.Style = yaml.FlowStyle
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error during the creation of the yaml file: %v", err)
+	}
+	defer file.Close()
+	encoder := yaml.NewEncoder(file)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return fmt.Errorf("error writing the yaml file: %v", err)
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Policies that restart the nodes stopped outside ContainMesh
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure" // Only the nodes that exit with a non zero code or are OOM killed
+	RestartAlways    = "always"
+)
+
+// RestartPolicies are the accepted values of WatchSettings.AutoRestart
+var RestartPolicies = []string{RestartNever, RestartOnFailure, RestartAlways}
+
+// WatchSettings describes the reaction to the changes of the nodes not made by ContainMesh
+type WatchSettings struct {
+	AutoRestart  string        `yaml:"AutoRestart,omitempty"`  // never (default), on-failure or always
+	MaxRestarts  int           `yaml:"MaxRestarts,omitempty"`  // Restarts of the same node, unlimited if 0
+	RestartDelay time.Duration `yaml:"RestartDelay,omitempty"` // Time waited before restarting a node
+}
+
+// Validate checks the consistency of the watch settings
+// It returns an error if the policy is not known or a value is negative
+func (w WatchSettings) Validate() error {
+	if w.AutoRestart != "" && !slices.Contains(RestartPolicies, w.AutoRestart) {
+		return fmt.Errorf("unknown restart policy %q, it must be one of %v", w.AutoRestart, RestartPolicies)
+	}
+	if w.MaxRestarts < 0 || w.RestartDelay < 0 {
+		return fmt.Errorf("the maximum number of restarts and the restart delay must not be negative")
+	}
+	return nil
+}
+
+// ShouldRestart reports whether a node that stopped with the given exit code must be restarted, given the number of times it has already been restarted
+func (w WatchSettings) ShouldRestart(exitCode int, oomKilled bool, restarts int) bool {
+	if w.MaxRestarts > 0 && restarts >= w.MaxRestarts {
+		return false
+	}
+	switch w.AutoRestart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 || oomKilled
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"ContainMesh/config"
 	"ContainMesh/utils"
 	"context"
+	"flag"
 	"fmt"
 	"os"
 
@@ -16,6 +17,7 @@ import (
 
 func main() {
 	// Command line arguments
+	flag.Usage = utils.Usage
 	config, err := config.ProcessCommandLineArgs()
 	if err != nil {
 		fmt.Printf("error during the parsing of the command line args: %v \n", err)
@@ -38,6 +40,32 @@ func main() {
 	}
 	defer cli.Close()
 
+	// Record the actions in the event journal, a new environment starts a new journal
+	err = utils.OpenEventJournal(utils.EventJournalPath(config), !utils.IsCommand(config.Args))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer utils.CloseEventJournal()
+
+	// Run the command on the running environment
+	if utils.IsCommand(config.Args) {
+		err = utils.RunCommand(cli, config, config.Args)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Edit the adjacency matrix if it is not set in the yaml file
+	if *config.NumNetworks > 1 && (config.NetMatrix == nil || *config.EditMatrix) {
+		err = utils.EditMatrix(config)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 	// Remove all the containers and networks if they already exist
 	err = utils.DeleteVirtualEnv(cli, config)
 	if err != nil {
@@ -63,6 +91,12 @@ func main() {
 		termFd, isTerm := term.GetFdInfo(os.Stderr)
 		jsonmessage.DisplayJSONMessagesStream(out, os.Stderr, termFd, isTerm, nil)
 	}
+	// Pull the images of the node groups that are missing
+	err = utils.PullNodeImages(cli, config)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	// Create the virtual environment
 	err = utils.LoadVirtualEnv(cli, config)
 	if err != nil {
@@ -70,27 +104,38 @@ func main() {
 		return
 	}
 
-	// Create the bash script to connect to the containers
-	err = utils.CreateConnectScript(config)
+	// Set the latencies of the links read from the topology file
+	err = utils.ApplyLinkProperties(cli, config)
 	if err != nil {
 		fmt.Println(err)
-		return
 	}
-	// Display the menu for the post creation options
-	err = utils.Menu(config, cli)
+
+	// Collect the resource usage of the nodes while the environment is up
+	stats := utils.NewStatsCollector(cli, config.MeshName(), utils.DefaultStatsHistory)
+	nodes, _ := config.ResolveTarget("all")
+	statsCtx, stopStats := context.WithCancel(context.Background())
+	go stats.Run(statsCtx, nodes)
+	// React to the changes of the nodes made outside ContainMesh
+	go utils.WatchEvents(statsCtx, cli, config)
+	// Serve the REST API while the environment is up
+	if *config.ApiAddress != "" {
+		go func() {
+			err := utils.StartApiServer(statsCtx, cli, config, stats, *config.ApiAddress)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+	// Display the live dashboard of the environment
+	err = utils.Dashboard(config, cli, stats)
 	if err != nil {
 		fmt.Println(err)
 	}
+	stopStats()
 	// Remove all the containers and networks
 	err = utils.DeleteVirtualEnv(cli, config)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	// Remove the bash script
-	err = os.Remove("connect_to_host.sh")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
 }
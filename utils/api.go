@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gin-gonic/gin"
+)
+
+// nodeParam reads the node number from the request path and checks that it is in range
+// It writes a bad request response and returns false if the node number is not valid
+func nodeParam(c *gin.Context, config *config.Config) (int, bool) {
+	node, err := strconv.Atoi(c.Param("node"))
+	if err != nil || node < 0 || node >= config.TotalNodes() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid node number " + c.Param("node")})
+		return 0, false
+	}
+	return node, true
+}
+
+// linkParam reads the link from the request path, written as "3:1"
+// It writes a bad request response and returns false if the link doesn't exist
+func linkParam(c *gin.Context, config *config.Config) (Link, bool) {
+	link, err := ParseLink(config, c.Param("link"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return Link{}, false
+	}
+	return link, true
+}
+
+// sameOrigin rejects the requests that change the environment when they come from another site, so that a page opened in the browser can't drive the API
+// The requests without Origin and Sec-Fetch-Site headers, e.g. made with curl, are accepted
+func sameOrigin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		site := c.GetHeader("Sec-Fetch-Site")
+		crossSite := site != "" && site != "same-origin" && site != "none"
+		if origin := c.GetHeader("Origin"); origin != "" {
+			parsed, err := url.Parse(origin)
+			crossSite = crossSite || err != nil || parsed.Host != c.Request.Host
+		}
+		if crossSite {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "cross-origin requests are not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiDuration is a duration of a request body, written as a duration string ("30s", "1m") or a number of seconds
+type apiDuration time.Duration
+
+func (d *apiDuration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %v", value, err)
+		}
+		*d = apiDuration(duration)
+	case float64:
+		*d = apiDuration(value * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s, it must be a duration string or a number of seconds", data)
+	}
+	return nil
+}
+
+// NewApiRouter creates the router of the REST API given a context, a pointer to a Docker client, a pointer to the config struct and the running stats collector
+// The event hub of the router runs until the context is done
+func NewApiRouter(ctx context.Context, cli *client.Client, cfg *config.Config, stats *StatsCollector) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), sameOrigin())
+	hub := NewEventHub(cli, cfg, stats)
+	go hub.Run(ctx)
+
+	router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/ui")
+	})
+	router.GET("/ui", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", webIndex)
+	})
+	router.GET("/graph", func(c *gin.Context) {
+		graph, err := GetGraphEncoding(cli, cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		graph["Stats"] = stats.LatestAll()
+		c.JSON(http.StatusOK, graph)
+	})
+	router.GET("/events", func(c *gin.Context) {
+		follow := c.Query("follow") == "true" || c.Query("follow") == "1"
+		action, outcome := c.Query("action"), c.Query("outcome")
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		err := ReadEvents(c.Request.Context(), EventJournalPath(cfg), follow, func(event Event) error {
+			if action != "" && event.Action != action || outcome != "" && event.Outcome != outcome {
+				return nil
+			}
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		})
+		if err != nil && c.Writer.Size() <= 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		filter, err := ParseStreamFilter(cfg, c.Query("node"), c.Query("type"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		events, unsubscribe := hub.Subscribe(filter)
+		defer unsubscribe()
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event := <-events:
+				c.SSEvent(event.Type, event)
+				return true
+			}
+		})
+	})
+	router.GET("/metrics", gin.WrapH(NewMetricsHandler(cli, cfg, stats)))
+	router.GET("/stats", func(c *gin.Context) {
+		c.JSON(http.StatusOK, stats.LatestAll())
+	})
+	router.GET("/nodes/:node/stats", func(c *gin.Context) {
+		node, ok := nodeParam(c, cfg)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"History": stats.History(node)})
+	})
+	router.PUT("/nodes/:node/resources", func(c *gin.Context) {
+		node, ok := nodeParam(c, cfg)
+		if !ok {
+			return
+		}
+		var settings config.ResourceSettings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := UpdateContainerResources(cli, node, cfg.MeshName(), settings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/nodes", func(c *gin.Context) {
+		statuses, err := GetNodesStatus(cli, cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, statuses)
+	})
+	router.GET("/nodes/:node", func(c *gin.Context) {
+		node, ok := nodeParam(c, cfg)
+		if !ok {
+			return
+		}
+		status, err := GetNodeStatus(cli, node, cfg.MeshName())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	})
+	router.POST("/nodes/:node/stop", func(c *gin.Context) {
+		node, ok := nodeParam(c, cfg)
+		if !ok {
+			return
+		}
+		if err := StopContainer(cli, node, cfg.MeshName()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/nodes/:node/start", func(c *gin.Context) {
+		node, ok := nodeParam(c, cfg)
+		if !ok {
+			return
+		}
+		if err := RestartContainer(cli, node, cfg.MeshName()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/links/:link/cut", func(c *gin.Context) {
+		link, ok := linkParam(c, cfg)
+		if !ok {
+			return
+		}
+		if err := DropLink(cli, cfg, link); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/links/:link/heal", func(c *gin.Context) {
+		link, ok := linkParam(c, cfg)
+		if !ok {
+			return
+		}
+		if err := HealLinks(cli, cfg, []Link{link}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/exec", func(c *gin.Context) {
+		var request struct {
+			Target  string       `json:"Target" binding:"required"`
+			Command string       `json:"Command" binding:"required"`
+			Timeout *apiDuration `json:"Timeout"` // The default timeout if not set
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		nodes, err := cfg.ResolveTarget(request.Target)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		timeout := defaultExecTimeout
+		if request.Timeout != nil {
+			timeout = time.Duration(*request.Timeout)
+		}
+		if timeout <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the timeout must be positive"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.JSON(http.StatusOK, ExecOnNodes(ctx, cli, cfg.MeshName(), nodes, ShellCommand(request.Command)))
+	})
+	return router
+}
+
+// StartApiServer serves the REST API on the given address until the context is done
+// It returns an error if the server can't be started
+func StartApiServer(ctx context.Context, cli *client.Client, config *config.Config, stats *StatsCollector, address string) error {
+	server := &http.Server{Addr: address, Handler: NewApiRouter(ctx, cli, config, stats)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
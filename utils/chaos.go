@@ -0,0 +1,446 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const chaosTick = time.Second // Resolution of the chaos scheduler
+
+// Actions of the chaos journal
+const (
+	ChaosStart  = "start"
+	ChaosInject = "inject"
+	ChaosHeal   = "heal"
+	ChaosEnd    = "end"
+)
+
+// ChaosEvent is an entry of the chaos journal
+type ChaosEvent struct {
+	Time     time.Time     `json:"Time"`
+	Offset   time.Duration `json:"Offset"` // Time elapsed since the start of the campaign
+	Action   string        `json:"Action"`
+	Fault    string        `json:"Fault,omitempty"`
+	Node     *int          `json:"Node,omitempty"`     // Target of the stop, pause and latency faults
+	Networks []int         `json:"Networks,omitempty"` // Target of the partition faults
+	Link     *Link         `json:"Link,omitempty"`     // Target of the link drop faults
+	Latency  time.Duration `json:"Latency,omitempty"`
+	Seed     int64         `json:"Seed,omitempty"`
+	Error    string        `json:"Error,omitempty"`
+}
+
+func (e ChaosEvent) String() string {
+	s := fmt.Sprintf("[%s +%v] %s", e.Time.Format(time.TimeOnly), e.Offset, e.Action)
+	if e.Fault != "" {
+		s += " " + e.Fault
+	}
+	switch {
+	case e.Node != nil:
+		s += fmt.Sprintf(" node %d", *e.Node)
+	case e.Networks != nil:
+		s += fmt.Sprintf(" networks %d|%d", e.Networks[0], e.Networks[1])
+	case e.Link != nil:
+		s += fmt.Sprintf(" link %d (%d->%d)", e.Link.Node, e.Link.From, e.Link.To)
+	}
+	if e.Action == ChaosStart {
+		s += fmt.Sprintf(" seed %d", e.Seed)
+	}
+	if e.Error != "" {
+		s += " failed: " + e.Error
+	}
+	return s
+}
+
+// chaosClock gives the time of a campaign and waits for the next events
+type chaosClock interface {
+	Now() time.Time
+	WaitUntil(ctx context.Context, t time.Time) bool
+}
+
+// systemClock is the clock of the campaigns run against the environment
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) WaitUntil(ctx context.Context, t time.Time) bool {
+	return waitUntil(ctx, t)
+}
+
+// chaosJournal writes the chaos events to a JSON lines file and to the standard output
+type chaosJournal struct {
+	file  *os.File
+	clock chaosClock
+	start time.Time
+}
+
+// newChaosJournal creates the journal file, an empty path disables the file
+// It returns an error if the file can't be created
+func newChaosJournal(path string, clock chaosClock) (*chaosJournal, error) {
+	journal := &chaosJournal{clock: clock, start: clock.Now()}
+	if path == "" {
+		return journal, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the chaos journal: %v", err)
+	}
+	journal.file = file
+	return journal, nil
+}
+
+// stamp sets the time of the event and its offset from the start of the campaign
+func (j *chaosJournal) stamp(event *ChaosEvent) {
+	event.Time = j.clock.Now()
+	event.Offset = event.Time.Sub(j.start).Truncate(time.Millisecond)
+}
+
+// log completes the event with its timestamps and writes it
+func (j *chaosJournal) log(event ChaosEvent) {
+	j.stamp(&event)
+	j.write(event)
+}
+
+// write writes an event that already has its timestamps
+func (j *chaosJournal) write(event ChaosEvent) {
+	fmt.Println(event)
+	if j.file != nil {
+		data, _ := json.Marshal(event)
+		j.file.Write(append(data, '\n'))
+		j.file.Sync()
+	}
+}
+
+func (j *chaosJournal) Close() error {
+	if j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// applyChaosEvent injects or heals the fault described by the event
+// It returns an error if the fault can't be applied
+func applyChaosEvent(cli *client.Client, config *config.Config, event ChaosEvent) error {
+	meshName := config.MeshName()
+	inject := event.Action == ChaosInject
+	switch event.Fault {
+	case FaultStop:
+		if inject {
+			return StopContainer(cli, *event.Node, meshName)
+		}
+		return RestartContainer(cli, *event.Node, meshName)
+	case FaultPause:
+		if inject {
+			return PauseContainer(cli, *event.Node, meshName)
+		}
+		return UnpauseContainer(cli, *event.Node, meshName)
+	case FaultLatency:
+		if inject {
+			return SetLatency(cli, *event.Node, meshName, event.Latency)
+		}
+		return SetLatency(cli, *event.Node, meshName, 0)
+	case FaultPartition:
+		if inject {
+			return PartitionNetworks(cli, config, event.Networks[0], event.Networks[1])
+		}
+		return HealPartition(cli, config, event.Networks[0], event.Networks[1])
+	case FaultLinkDrop:
+		if inject {
+			return DropLink(cli, config, *event.Link)
+		}
+		return HealLinks(cli, config, []Link{*event.Link})
+	}
+	return fmt.Errorf("unknown fault %q", event.Fault)
+}
+
+// activeChaosFault is a fault injected by the scheduler and the tick when it must be healed
+type activeChaosFault struct {
+	event    ChaosEvent
+	healTick int
+}
+
+// chaosScheduler picks the faults of a campaign, its choices depend only on the seed
+type chaosScheduler struct {
+	config   *config.Config
+	settings config.ChaosSettings
+	rng      *rand.Rand
+	active   []activeChaosFault
+}
+
+// busy returns the nodes and the links that are the target of an active fault
+func (s *chaosScheduler) busy() (map[int]bool, map[Link]bool) {
+	nodes := map[int]bool{}
+	links := map[Link]bool{}
+	for _, fault := range s.active {
+		if fault.event.Node != nil {
+			nodes[*fault.event.Node] = true
+		}
+		if fault.event.Link != nil {
+			links[*fault.event.Link] = true
+		}
+		if fault.event.Networks != nil {
+			for _, link := range PartitionLinks(s.config, fault.event.Networks[0], fault.event.Networks[1]) {
+				links[link] = true
+			}
+		}
+	}
+	return nodes, links
+}
+
+// pickTarget chooses a random target for the fault among the ones not affected by an active fault
+// It returns false if there is no available target
+func (s *chaosScheduler) pickTarget(event *ChaosEvent) bool {
+	busyNodes, busyLinks := s.busy()
+	switch event.Fault {
+	case FaultStop, FaultPause, FaultLatency:
+		var candidates []int
+		for node := 0; node < s.config.TotalNodes(); node++ {
+			if !busyNodes[node] {
+				candidates = append(candidates, node)
+			}
+		}
+		if len(candidates) == 0 {
+			return false
+		}
+		node := candidates[s.rng.Intn(len(candidates))]
+		event.Node = &node
+		if event.Fault == FaultLatency {
+			event.Latency = s.settings.Latency
+		}
+	case FaultPartition:
+		var candidates [][]int
+		for i := 0; i < *s.config.NumNetworks; i++ {
+			for j := i + 1; j < *s.config.NumNetworks; j++ {
+				links := PartitionLinks(s.config, i, j)
+				free := len(links) > 0
+				for _, link := range links {
+					free = free && !busyLinks[link]
+				}
+				if free {
+					candidates = append(candidates, []int{i, j})
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			return false
+		}
+		event.Networks = candidates[s.rng.Intn(len(candidates))]
+	case FaultLinkDrop:
+		var candidates []Link
+		for _, link := range Links(s.config) {
+			if !busyLinks[link] {
+				candidates = append(candidates, link)
+			}
+		}
+		if len(candidates) == 0 {
+			return false
+		}
+		link := candidates[s.rng.Intn(len(candidates))]
+		event.Link = &link
+	}
+	return true
+}
+
+// next returns the faults to heal and the faults to inject at the given tick
+func (s *chaosScheduler) next(tick int) ([]ChaosEvent, []ChaosEvent) {
+	var heal []ChaosEvent
+	var still []activeChaosFault
+	for _, fault := range s.active {
+		if fault.healTick <= tick {
+			event := fault.event
+			event.Action = ChaosHeal
+			heal = append(heal, event)
+		} else {
+			still = append(still, fault)
+		}
+	}
+	s.active = still
+
+	// The faults are visited in a fixed order to keep the sequence of random numbers reproducible
+	faults := make([]string, 0, len(s.settings.Faults))
+	for fault := range s.settings.Faults {
+		faults = append(faults, fault)
+	}
+	sort.Strings(faults)
+	minTicks := int(s.settings.MinFaultDuration / chaosTick)
+	maxTicks := int(s.settings.MaxFaultDuration / chaosTick)
+	var inject []ChaosEvent
+	for _, fault := range faults {
+		if len(s.active) >= s.settings.MaxConcurrentFaults {
+			break
+		}
+		if s.rng.Float64() >= s.settings.Faults[fault]*chaosTick.Minutes() {
+			continue
+		}
+		event := ChaosEvent{Action: ChaosInject, Fault: fault}
+		if !s.pickTarget(&event) {
+			continue
+		}
+		duration := minTicks + s.rng.Intn(maxTicks-minTicks+1)
+		s.active = append(s.active, activeChaosFault{event, tick + duration})
+		inject = append(inject, event)
+	}
+	return heal, inject
+}
+
+// healAll returns the heal events of every active fault and forgets them
+func (s *chaosScheduler) healAll() []ChaosEvent {
+	var heal []ChaosEvent
+	for _, fault := range s.active {
+		event := fault.event
+		event.Action = ChaosHeal
+		heal = append(heal, event)
+	}
+	s.active = nil
+	return heal
+}
+
+// runChaosEvents applies the events and logs them in the journal with their outcome
+// The events are stamped when they are applied, a slow operation doesn't shift the offsets replayed later
+func runChaosEvents(apply func(ChaosEvent) error, journal *chaosJournal, events []ChaosEvent) {
+	for _, event := range events {
+		journal.stamp(&event)
+		if err := apply(event); err != nil {
+			event.Error = err.Error()
+		}
+		journal.write(event)
+	}
+}
+
+// waitUntil waits until the given time or until the context is done
+// It returns false if the context is done
+func waitUntil(ctx context.Context, t time.Time) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(time.Until(t)):
+		return true
+	}
+}
+
+// RunChaos runs a randomized fault campaign on the running virtual environment given the chaos settings
+// Every fault is healed at the end of the campaign or when the context is done
+// It returns an error if the settings are not valid or the journal can't be created
+func RunChaos(ctx context.Context, cli *client.Client, config *config.Config, settings config.ChaosSettings) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("error in the chaos settings: %v", err)
+	}
+	if settings.Seed == 0 {
+		settings.Seed = time.Now().UnixNano()
+	}
+	journal, err := newChaosJournal(settings.Journal, systemClock{})
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	runChaos(ctx, journal, config, settings, func(event ChaosEvent) error { return applyChaosEvent(cli, config, event) })
+	return nil
+}
+
+// runChaos runs the campaign of the settings, whose seed is set, and applies its events with the given function
+func runChaos(ctx context.Context, journal *chaosJournal, config *config.Config, settings config.ChaosSettings, apply func(ChaosEvent) error) {
+	scheduler := &chaosScheduler{
+		config:   config,
+		settings: settings,
+		rng:      rand.New(rand.NewSource(settings.Seed)),
+	}
+	journal.log(ChaosEvent{Action: ChaosStart, Seed: settings.Seed})
+	ticks := int(settings.Duration / chaosTick)
+	for tick := 0; tick < ticks; tick++ {
+		if !journal.clock.WaitUntil(ctx, journal.start.Add(time.Duration(tick)*chaosTick)) {
+			break
+		}
+		heal, inject := scheduler.next(tick)
+		runChaosEvents(apply, journal, heal)
+		runChaosEvents(apply, journal, inject)
+	}
+	runChaosEvents(apply, journal, scheduler.healAll())
+	journal.log(ChaosEvent{Action: ChaosEnd})
+}
+
+// ReadChaosJournal reads the events of a chaos journal
+// It returns an error if the file can't be read or an event can't be decoded
+func ReadChaosJournal(path string) ([]ChaosEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the chaos journal: %v", err)
+	}
+	defer file.Close()
+	var events []ChaosEvent
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var event ChaosEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("error decoding the line %d of the chaos journal: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReplayChaos applies again the faults of a chaos journal at the same offsets from the start
+// The faults left active when the context is done are healed
+// It returns an error if the journal can't be read
+func ReplayChaos(ctx context.Context, cli *client.Client, config *config.Config, path string, journalPath string) error {
+	events, err := ReadChaosJournal(path)
+	if err != nil {
+		return err
+	}
+	journal, err := newChaosJournal(journalPath, systemClock{})
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	replayChaos(ctx, journal, events, func(event ChaosEvent) error { return applyChaosEvent(cli, config, event) })
+	return nil
+}
+
+// replayChaos applies the events of a journal with the given function at their offsets from the start of the new journal
+func replayChaos(ctx context.Context, journal *chaosJournal, events []ChaosEvent, apply func(ChaosEvent) error) {
+	active := map[string]ChaosEvent{}
+	for _, event := range events {
+		if event.Action != ChaosInject && event.Action != ChaosHeal {
+			journal.log(ChaosEvent{Action: event.Action, Seed: event.Seed})
+			continue
+		}
+		if !journal.clock.WaitUntil(ctx, journal.start.Add(event.Offset)) {
+			break
+		}
+		key := event.Fault + event.targetKey()
+		if event.Action == ChaosInject {
+			active[key] = event
+		} else {
+			delete(active, key)
+		}
+		event.Error = ""
+		runChaosEvents(apply, journal, []ChaosEvent{event})
+	}
+	// Heal what was left active by an interruption
+	for _, event := range active {
+		event.Action = ChaosHeal
+		runChaosEvents(apply, journal, []ChaosEvent{event})
+	}
+}
+
+// targetKey returns a string that identifies the target of the event
+func (e ChaosEvent) targetKey() string {
+	switch {
+	case e.Node != nil:
+		return fmt.Sprintf("node%d", *e.Node)
+	case e.Networks != nil:
+		return fmt.Sprintf("networks%v", e.Networks)
+	case e.Link != nil:
+		return fmt.Sprintf("link%v", *e.Link)
+	}
+	return ""
+}
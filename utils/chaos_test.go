@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock runs a campaign instantly, every applied fault takes a fixed time
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) WaitUntil(ctx context.Context, t time.Time) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if t.After(c.now) {
+		c.now = t
+	}
+	return true
+}
+
+// chaosTestConfig returns a mesh of 3 fully linked networks of 3 nodes
+func chaosTestConfig() *config.Config {
+	numNetworks, numContainers, numLinks := 3, 3, 1
+	matrix := [][]bool{{false, true, true}, {true, false, true}, {true, true, false}}
+	return &config.Config{NumNetworks: &numNetworks, NumContainers: &numContainers, NumLinks: &numLinks, NetMatrix: matrix}
+}
+
+// runTestChaos runs a campaign with a fake clock and returns the journal read back and the applied events
+func runTestChaos(t *testing.T, run func(journal *chaosJournal, apply func(ChaosEvent) error)) ([]ChaosEvent, []ChaosEvent) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	path := filepath.Join(t.TempDir(), "chaos_journal.jsonl")
+	journal, err := newChaosJournal(path, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied []ChaosEvent
+	run(journal, func(event ChaosEvent) error {
+		applied = append(applied, event)
+		clock.now = clock.now.Add(150 * time.Millisecond)
+		return nil
+	})
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadChaosJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events, applied
+}
+
+func TestChaosCampaign(t *testing.T) {
+	settings := config.ChaosSettings{
+		Seed:                42,
+		Duration:            5 * time.Minute,
+		MaxConcurrentFaults: 3,
+		MinFaultDuration:    5 * time.Second,
+		MaxFaultDuration:    30 * time.Second,
+		Latency:             100 * time.Millisecond,
+		Faults:              map[string]float64{FaultStop: 3, FaultPause: 3, FaultLatency: 3, FaultPartition: 2, FaultLinkDrop: 2},
+	}
+	campaign := func(journal *chaosJournal, apply func(ChaosEvent) error) {
+		runChaos(context.Background(), journal, chaosTestConfig(), settings, apply)
+	}
+	first, applied := runTestChaos(t, campaign)
+	second, _ := runTestChaos(t, campaign)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("the journals of two campaigns with the same seed differ:\n%v\n%v", first, second)
+	}
+
+	// Every fault is healed by the end of the campaign
+	injected := 0
+	active := map[string]bool{}
+	for _, event := range applied {
+		key := event.Fault + event.targetKey()
+		switch event.Action {
+		case ChaosInject:
+			injected++
+			if active[key] {
+				t.Errorf("%v injected twice", event)
+			}
+			active[key] = true
+		case ChaosHeal:
+			if !active[key] {
+				t.Errorf("%v healed without being injected", event)
+			}
+			delete(active, key)
+		}
+	}
+	if injected == 0 {
+		t.Errorf("no fault injected in %v", settings.Duration)
+	}
+	if len(active) != 0 {
+		t.Errorf("faults left active: %v", active)
+	}
+
+	// The replay of the journal applies the same faults at the same offsets
+	replayed, reapplied := runTestChaos(t, func(journal *chaosJournal, apply func(ChaosEvent) error) {
+		replayChaos(context.Background(), journal, first, apply)
+	})
+	if !reflect.DeepEqual(replayed, first) {
+		t.Errorf("the journal of the replay differs from the original:\n%v\n%v", replayed, first)
+	}
+	if len(reapplied) != len(applied) {
+		t.Errorf("the replay applied %d events, want %d", len(reapplied), len(applied))
+	}
+}
+
+func TestReplayChaosInterrupted(t *testing.T) {
+	node := 4
+	events := []ChaosEvent{
+		{Action: ChaosStart, Seed: 1},
+		{Action: ChaosInject, Fault: FaultStop, Node: &node, Offset: time.Second},
+		{Action: ChaosHeal, Fault: FaultStop, Node: &node, Offset: time.Minute},
+		{Action: ChaosEnd, Offset: time.Minute},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	_, applied := runTestChaos(t, func(journal *chaosJournal, apply func(ChaosEvent) error) {
+		replayChaos(ctx, journal, events, func(event ChaosEvent) error {
+			// The campaign is interrupted once the fault is injected
+			cancel()
+			return apply(event)
+		})
+	})
+	want := []ChaosEvent{{Action: ChaosInject, Fault: FaultStop, Node: &node}, {Action: ChaosHeal, Fault: FaultStop, Node: &node}}
+	if len(applied) != len(want) {
+		t.Fatalf("applied %v, want %v", applied, want)
+	}
+	for i := range want {
+		if applied[i].Action != want[i].Action || applied[i].targetKey() != want[i].targetKey() {
+			t.Errorf("applied %v, want %v", applied[i], want[i])
+		}
+	}
+}
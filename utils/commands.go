@@ -0,0 +1,764 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// command is a subcommand that operates on a running virtual environment
+type command struct {
+	args string // Arguments of the command, shown in the usage
+	help string
+	run  func(cli *client.Client, config *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"up": {
+		help: "create the virtual environment and show the dashboard (default)",
+	},
+	"list": {
+		args: "[-json]",
+		help: "list the virtual environments running on the host, with their project, nodes, networks and subnets",
+		run:  runListCommand,
+	},
+	"status": {
+		help: "print the status of the containers",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			return PrintNodesStatus(cli, config)
+		},
+	},
+	"stop": {
+		args: "<nodes>",
+		help: "stop the containers",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			return forEachNode(config, args, func(node int) error {
+				return StopContainer(cli, node, config.MeshName())
+			})
+		},
+	},
+	"start": {
+		args: "<nodes>",
+		help: "start the stopped containers",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			return forEachNode(config, args, func(node int) error {
+				return RestartContainer(cli, node, config.MeshName())
+			})
+		},
+	},
+	"pause": {
+		args: "<nodes>",
+		help: "freeze the processes of the containers",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			return forEachNode(config, args, func(node int) error {
+				return PauseContainer(cli, node, config.MeshName())
+			})
+		},
+	},
+	"unpause": {
+		args: "<nodes>",
+		help: "resume the processes of the paused containers",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			return forEachNode(config, args, func(node int) error {
+				return UnpauseContainer(cli, node, config.MeshName())
+			})
+		},
+	},
+	"kill": {
+		args: "<nodes> [signal]",
+		help: "send a signal to the containers (SIGKILL by default, SIGSTOP to freeze them, SIGCONT to resume them)",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			signal := ""
+			if len(args) > 1 {
+				signal = args[1]
+			}
+			return forEachNode(config, args, func(node int) error {
+				return KillContainer(cli, node, config.MeshName(), signal)
+			})
+		},
+	},
+	"restart": {
+		args: "<nodes> [delay]",
+		help: "stop the containers and start them again after the delay (e.g. 10s)",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			var delay time.Duration
+			if len(args) > 1 {
+				var err error
+				delay, err = time.ParseDuration(args[1])
+				if err != nil {
+					return fmt.Errorf("invalid delay %q: %v", args[1], err)
+				}
+			}
+			return forEachNode(config, args, func(node int) error {
+				return RestartContainerWithDelay(cli, node, config.MeshName(), delay)
+			})
+		},
+	},
+	"latency": {
+		args: "<nodes> <delay>",
+		help: "add a delay to all the interfaces of the containers with tc netem (0 to remove it)",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("missing the delay")
+			}
+			latency, err := time.ParseDuration(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid delay %q: %v", args[1], err)
+			}
+			return forEachNode(config, args, func(node int) error {
+				return SetLatency(cli, node, config.MeshName(), latency)
+			})
+		},
+	},
+	"partition": {
+		args: "<network>|<network>",
+		help: "cut all the links between two networks",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("missing the networks, e.g. 0|1")
+			}
+			network1, network2, err := ParseNetworkPair(config, args[0])
+			if err != nil {
+				return err
+			}
+			return PartitionNetworks(cli, config, network1, network2)
+		},
+	},
+	"drop-link": {
+		args: "<node>:<network>",
+		help: "disconnect a bridge node from the network it links",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("missing the link, e.g. 3:1")
+			}
+			link, err := ParseLink(config, args[0])
+			if err != nil {
+				return err
+			}
+			return DropLink(cli, config, link)
+		},
+	},
+	"heal": {
+		args: "[all|<network>|<network>|<node>:<network>]",
+		help: "heal every fault (default), a partition or a dropped link",
+		run: func(cli *client.Client, cfg *config.Config, args []string) error {
+			step := config.ScenarioStep{Action: "heal"}
+			if len(args) > 0 {
+				step.Target = args[0]
+			}
+			_, err := runScenarioStep(context.Background(), cli, cfg, step)
+			return err
+		},
+	},
+	"scenario": {
+		args: "<file> [-report file]",
+		help: "run the timed steps of a scenario file and print a pass/fail report",
+		run:  runScenarioCommand,
+	},
+	"exec": {
+		args: "[-json] [-timeout d] <target> <command>",
+		help: "run a command on the targets (all, net:<network>, a node group or nodes) and print the output grouped by node",
+		run:  runExecCommand,
+	},
+	"shell": {
+		args: "[-shell cmd] [-user u] [-workdir dir] <node>",
+		help: "open an interactive shell in a node, given by its container name, its number or a node group (its first node)",
+		run:  runShellCommand,
+	},
+	"copy-in": {
+		args: "<target> <local path> <node directory>",
+		help: "copy a local file or directory into the targets, the local path can contain {node}, {name} and {network}",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			if len(args) < 3 {
+				return fmt.Errorf("missing the target, the local path or the node directory")
+			}
+			nodes, err := config.ResolveTarget(args[0])
+			if err != nil {
+				return err
+			}
+			return CopyToNodes(context.Background(), cli, config, nodes, args[1], args[2])
+		},
+	},
+	"copy-out": {
+		args: "<target> <node path> <local directory>",
+		help: "copy a file or directory of the targets into a local directory, e.g. ./logs/{node}",
+		run: func(cli *client.Client, config *config.Config, args []string) error {
+			if len(args) < 3 {
+				return fmt.Errorf("missing the target, the node path or the local directory")
+			}
+			nodes, err := config.ResolveTarget(args[0])
+			if err != nil {
+				return err
+			}
+			return CopyFromNodes(context.Background(), cli, config, nodes, args[1], args[2])
+		},
+	},
+	"logs": {
+		args: "[-follow] [-since t] [-tail n] [-grep re] [-output file] [target]",
+		help: "print the logs of the targets (all by default) prefixed by the node name, merged in timestamp order",
+		run:  runLogsCommand,
+	},
+	"stats": {
+		args: "[-json] [-watch] [-interval d] [-history n] [target]",
+		help: "print the CPU, memory, network and block IO usage of the targets (all by default)",
+		run:  runStatsCommand,
+	},
+	"events": {
+		args: "[-follow] [-json] [-action a] [-errors]",
+		help: "print the journal of the actions on the containers and networks",
+		run:  runEventsCommand,
+	},
+	"export": {
+		args: "[-format dot|mermaid|json|compose] [-live] [-output file]",
+		help: "write the topology (networks, nodes, links and their faults) as a Graphviz, Mermaid or node-link JSON graph, or the expanded configuration as a docker-compose file",
+		run:  runExportCommand,
+	},
+	"snapshot": {
+		args: "<name>",
+		help: "commit the filesystem of every node to an image and save the topology, the addresses and the state of the nodes",
+		run:  runSnapshotCommand,
+	},
+	"restore": {
+		args: "<name>",
+		help: "recreate the environment from a snapshot with the same topology, addresses, stopped nodes and cut links",
+		run:  runRestoreCommand,
+	},
+	"snapshots": {
+		args: "[-json]",
+		help: "list the snapshots of the environment",
+		run:  runSnapshotsCommand,
+	},
+	"delete-snapshot": {
+		args: "<name>",
+		help: "remove the images and the file of a snapshot",
+		run:  runDeleteSnapshotCommand,
+	},
+	"import-compose": {
+		args: "<compose file> [-output file]",
+		help: "build a ContainMesh yaml configuration from the services and networks of a docker-compose file",
+		run:  runImportComposeCommand,
+	},
+	"chaos": {
+		args: "[-seed n] [-duration d] [-max n] [-faults f=rate,...] [-journal file]",
+		help: "inject random faults (stop, pause, partition, latency, link-drop, rates in faults per minute) and log them in a journal",
+		run:  runChaosCommand,
+	},
+	"replay": {
+		args: "<journal> [-journal file]",
+		help: "inject again the faults of a chaos journal at the same times",
+		run:  runReplayCommand,
+	},
+}
+
+// runChaosCommand parses the options of the chaos command, they override the chaos settings of the yaml file, and runs the campaign until it ends or it is interrupted
+// It returns an error if an option is not valid
+func runChaosCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	settings := cfg.Chaos
+	flags := flag.NewFlagSet("chaos", flag.ContinueOnError)
+	flags.Int64Var(&settings.Seed, "seed", settings.Seed, "Seed of the random generator, random if 0")
+	flags.DurationVar(&settings.Duration, "duration", settings.Duration, "Length of the campaign")
+	flags.IntVar(&settings.MaxConcurrentFaults, "max", settings.MaxConcurrentFaults, "Maximum number of faults active at the same time")
+	flags.DurationVar(&settings.Latency, "latency", settings.Latency, "Delay added by the latency spikes")
+	flags.StringVar(&settings.Journal, "journal", settings.Journal, "File where the actions are logged")
+	faults := flags.String("faults", "", "Rates of the faults in faults per minute, e.g. stop=0.5,partition=0.1")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *faults != "" {
+		settings.Faults = map[string]float64{}
+		for _, f := range strings.Split(*faults, ",") {
+			name, rate, ok := strings.Cut(f, "=")
+			value, err := strconv.ParseFloat(rate, 64)
+			if !ok || err != nil {
+				return fmt.Errorf("invalid fault rate %q", f)
+			}
+			settings.Faults[strings.TrimSpace(name)] = value
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return RunChaos(ctx, cli, cfg, settings)
+}
+
+// runScenarioCommand runs a scenario file until it ends or it is interrupted and prints its report
+// It returns an error if the scenario can't be loaded or if it fails
+func runScenarioCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing the scenario file")
+	}
+	flags := flag.NewFlagSet("scenario", flag.ContinueOnError)
+	reportPath := flags.String("report", "", "File where the JSON report is written")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	scenario, err := config.LoadScenario(args[0])
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report := RunScenario(ctx, cli, cfg, scenario)
+	report.Print()
+	if *reportPath != "" {
+		if err := report.WriteJSON(*reportPath); err != nil {
+			return err
+		}
+	}
+	if !report.Passed {
+		return fmt.Errorf("scenario %s failed", scenario.Name)
+	}
+	return nil
+}
+
+// runExecCommand runs a shell command concurrently on the target nodes and prints the grouped results or their JSON encoding
+// It returns an error if the command fails on a node
+func runExecCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the results as JSON")
+	timeout := flags.Duration("timeout", defaultExecTimeout, "Maximum duration of the command")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("missing the target or the command")
+	}
+	nodes, err := cfg.ResolveTarget(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	results := ExecOnNodes(ctx, cli, cfg.MeshName(), nodes, ShellCommand(strings.Join(flags.Args()[1:], " ")))
+	if *asJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		PrintExecResults(results)
+	}
+	if failed := FailedNodes(results); len(failed) > 0 {
+		return fmt.Errorf("the command failed on nodes %s", config.FormatNodeSelection(failed))
+	}
+	return nil
+}
+
+// runListCommand prints the virtual environments of the host, whatever their project
+// It returns an error if an option is not valid or the environments can't be listed
+func runListCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the environments as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	environments, err := ListEnvironments(cli)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(environments, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	PrintEnvironments(environments)
+	return nil
+}
+
+// runShellCommand opens an interactive shell in a node, the options override the node settings of the yaml file
+// It returns an error if the node doesn't exist, the shell can't be opened or it exits with a non-zero code
+func runShellCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	options := ShellOptionsOf(cfg.Node)
+	flags := flag.NewFlagSet("shell", flag.ContinueOnError)
+	flags.StringVar(&options.Shell, "shell", options.Shell, "Shell to run, with its arguments")
+	flags.StringVar(&options.User, "user", options.User, "User (and group) running the shell, e.g. root or 1000:1000")
+	flags.StringVar(&options.WorkDir, "workdir", options.WorkDir, "Working directory of the shell")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("missing the node")
+	}
+	node, err := ResolveShellNode(cfg, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	exitCode, err := OpenShell(context.Background(), cli, node, cfg.MeshName(), options)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("the shell exited with code %d", exitCode)
+	}
+	return nil
+}
+
+// runLogsCommand prints the logs of the target nodes until they end or, when following, until it is interrupted
+// It returns an error if an option is not valid or the logs can't be read
+func runLogsCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	var options LogOptions
+	flags.BoolVar(&options.Follow, "follow", false, "Keep printing the new lines")
+	flags.StringVar(&options.Since, "since", "", "Show the lines since a timestamp or a relative time (e.g. 10m)")
+	flags.StringVar(&options.Tail, "tail", "", "Number of lines to show from the end of the logs of every node")
+	grep := flags.String("grep", "", "Show only the lines that match the regular expression")
+	output := flags.String("output", "", "File where the merged logs are written")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *grep != "" {
+		var err error
+		options.Grep, err = regexp.Compile(*grep)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", *grep, err)
+		}
+	}
+	target := "all"
+	if flags.NArg() > 0 {
+		target = flags.Arg(0)
+	}
+	nodes, err := cfg.ResolveTarget(target)
+	if err != nil {
+		return err
+	}
+	printer := &LogPrinter{Out: os.Stdout}
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error creating the log file: %v", err)
+		}
+		defer file.Close()
+		printer.Merged = file
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return StreamLogs(ctx, cli, cfg.MeshName(), nodes, options, printer.Print)
+}
+
+// runStatsCommand samples the resource usage of the target nodes and prints it, until it is interrupted when watching
+// It returns an error if an option is not valid
+func runStatsCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the samples as JSON")
+	watch := flags.Bool("watch", false, "Keep printing the usage")
+	interval := flags.Duration("interval", 2*time.Second, "Interval between two prints when watching")
+	history := flags.Int("history", 0, "Print the last n samples of every node as JSON instead of the usage")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("the interval must be positive")
+	}
+	target := "all"
+	if flags.NArg() > 0 {
+		target = flags.Arg(0)
+	}
+	nodes, err := cfg.ResolveTarget(target)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	collector := NewStatsCollector(cli, cfg.MeshName(), max(*history, DefaultStatsHistory))
+	go collector.Run(ctx, nodes)
+
+	// The first sample of a node has no rates, wait for the second one
+	samples := max(*history, 2)
+	wait, cancel := context.WithTimeout(ctx, time.Duration(samples+2)*time.Second)
+	collector.WaitSamples(wait, nodes, samples)
+	cancel()
+	for {
+		switch {
+		case *history > 0:
+			result := map[int][]NodeStats{}
+			for _, node := range nodes {
+				samples := collector.History(node)
+				result[node] = samples[max(len(samples)-*history, 0):]
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+				return err
+			}
+		case *asJSON:
+			var latest []NodeStats
+			for _, node := range nodes {
+				if stats, ok := collector.Latest(node); ok {
+					latest = append(latest, stats)
+				}
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(latest); err != nil {
+				return err
+			}
+		default:
+			if *watch {
+				fmt.Println(time.Now().Format(time.TimeOnly))
+			}
+			WriteStatsTable(os.Stdout, nodes, collector)
+		}
+		if !*watch || !waitUntil(ctx, time.Now().Add(*interval)) {
+			return nil
+		}
+	}
+}
+
+// runEventsCommand prints the event journal until its end or, when following, until it is interrupted
+// It returns an error if an option is not valid or the journal can't be read
+func runEventsCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	follow := flags.Bool("follow", false, "Keep printing the new events")
+	asJSON := flags.Bool("json", false, "Print the events as JSON lines")
+	action := flags.String("action", "", "Print only the events of an action (e.g. stop_container)")
+	errors := flags.Bool("errors", false, "Print only the failed actions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	encoder := json.NewEncoder(os.Stdout)
+	return ReadEvents(ctx, EventJournalPath(cfg), *follow, func(event Event) error {
+		if *action != "" && event.Action != *action || *errors && event.Outcome != OutcomeError {
+			return nil
+		}
+		if *asJSON {
+			return encoder.Encode(event)
+		}
+		fmt.Println(event)
+		return nil
+	})
+}
+
+// runExportCommand writes the topology of the virtual environment in the requested format, the format is guessed from the output file if it is not set
+// It returns an error if an option is not valid or the topology can't be written
+func runExportCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "", "Format of the graph: dot (default), mermaid, json or compose")
+	live := flags.Bool("live", false, "Add the live status of the nodes")
+	output := flags.String("output", "", "File where the graph is written instead of the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = ExportDOT
+		if guessed, ok := ExportFormatFromPath(*output); ok {
+			*format = guessed
+		}
+	}
+	if !slices.Contains(ExportFormats, *format) {
+		return fmt.Errorf("unknown export format %q, it must be one of %v", *format, ExportFormats)
+	}
+	// The compose file describes the configuration, not the state of the topology
+	write := func(w io.Writer) error { return WriteCompose(w, cfg) }
+	if *format != ExportCompose {
+		graph, err := BuildTopologyGraph(cli, cfg, *live)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error { return ExportTopology(w, graph, *format) }
+	}
+	if *output == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("error creating the export file: %v", err)
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// runSnapshotCommand checkpoints the virtual environment in a snapshot
+// It returns an error if the name is missing or the snapshot can't be created
+func runSnapshotCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("missing the name of the snapshot")
+	}
+	return CreateSnapshot(cli, cfg, args[0])
+}
+
+// runRestoreCommand rolls the virtual environment back to a snapshot
+// It returns an error if the name is missing or the snapshot can't be restored
+func runRestoreCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("missing the name of the snapshot")
+	}
+	return RestoreSnapshot(cli, cfg, args[0])
+}
+
+// runSnapshotsCommand prints the snapshots of the virtual environment
+// It returns an error if an option is not valid or the snapshots can't be read
+func runSnapshotsCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("snapshots", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the snapshots as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	snapshots, err := ListSnapshots(cfg.MeshName())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(snapshots, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	PrintSnapshots(snapshots)
+	return nil
+}
+
+// runDeleteSnapshotCommand removes a snapshot of the virtual environment
+// It returns an error if the name is missing or the snapshot can't be removed
+func runDeleteSnapshotCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("missing the name of the snapshot")
+	}
+	return DeleteSnapshot(cli, cfg, args[0])
+}
+
+// runImportComposeCommand converts a docker-compose file into a ContainMesh yaml configuration and prints the approximations made
+// It returns an error if the compose file can't be converted or the configuration can't be written
+func runImportComposeCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing the compose file to import")
+	}
+	flags := flag.NewFlagSet("import-compose", flag.ContinueOnError)
+	output := flags.String("output", "", "File where the configuration is written instead of the standard output")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	yamlConf, warnings, err := ImportCompose(args[0])
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return WriteImportedCompose(os.Stdout, args[0], yamlConf, warnings)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("error creating the configuration file: %v", err)
+	}
+	defer file.Close()
+	if err := WriteImportedCompose(file, args[0], yamlConf, warnings); err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return file.Close()
+}
+
+// runReplayCommand replays a chaos journal until it ends or it is interrupted
+// It returns an error if the journal is missing or can't be read
+func runReplayCommand(cli *client.Client, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing the chaos journal to replay")
+	}
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	journal := flags.String("journal", "", "File where the replayed actions are logged")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ReplayChaos(ctx, cli, cfg, args[0], *journal)
+}
+
+// forEachNode runs the function concurrently on every node selected by the first argument (e.g. "0-4,7")
+// It returns an error if the selection is not valid or if the function fails on a node
+func forEachNode(config *config.Config, args []string, fn func(node int) error) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing the nodes, they are numbered from 0 to %d", config.TotalNodes()-1)
+	}
+	nodes, err := config.ParseNodes(args[0])
+	if err != nil {
+		return err
+	}
+	return forNodes(nodes, fn)
+}
+
+// forNodes runs the function concurrently on every node
+// It returns the errors of all the nodes where the function fails
+func forNodes(nodes []int, fn func(node int) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(nodes))
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node int) {
+			defer wg.Done()
+			errs[i] = fn(node)
+		}(i, node)
+	}
+	wg.Wait()
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+	return nil
+}
+
+// IsCommand reports whether the arguments select a command other than up
+func IsCommand(args []string) bool {
+	return len(args) > 0 && args[0] != "up"
+}
+
+// RunCommand runs the command selected by the first argument on the running virtual environment
+// It returns an error if the command is unknown or fails
+func RunCommand(cli *client.Client, config *config.Config, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok || cmd.run == nil {
+		return fmt.Errorf("unknown command %q, see %s -h", args[0], os.Args[0])
+	}
+	if err := LoadTopology(config); err != nil {
+		return err
+	}
+	return cmd.run(cli, config, args[1:])
+}
+
+// Usage prints the usage of the program with the list of the commands
+func Usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [options] [command] [arguments]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		synopsis := strings.TrimSpace(name + " " + commands[name].args)
+		if len(synopsis) > 28 {
+			// Long synopses get the help on the next line
+			fmt.Fprintf(out, "  %s\n  %-28s %s\n", synopsis, "", commands[name].help)
+		} else {
+			fmt.Fprintf(out, "  %-28s %s\n", synopsis, commands[name].help)
+		}
+	}
+	fmt.Fprintf(out, "\nThe nodes are selected by number, e.g. 3, 0-4 or 0,2,5-7\n\nOptions:\n")
+	flag.PrintDefaults()
+}
@@ -0,0 +1,688 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeNames is a list of names written as a sequence or as the keys of a mapping, as the networks and the dependencies of a service
+type composeNames []string
+
+func (n *composeNames) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var names []string
+		if err := value.Decode(&names); err != nil {
+			return err
+		}
+		*n = names
+	case yaml.MappingNode:
+		// The keys are kept in the order of the document
+		for i := 0; i < len(value.Content); i += 2 {
+			*n = append(*n, value.Content[i].Value)
+		}
+	default:
+		return fmt.Errorf("line %d: expected a list or a mapping", value.Line)
+	}
+	return nil
+}
+
+// composeCommand is a command written as a list or as a string, a string is split into words as compose does, without a shell
+type composeCommand []string
+
+func (c *composeCommand) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		words, err := splitCommandLine(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", value.Line, err)
+		}
+		*c = words
+		return nil
+	}
+	var command []string
+	if err := value.Decode(&command); err != nil {
+		return err
+	}
+	*c = command
+	return nil
+}
+
+// composeTest is the command of a health check, a list starting with NONE, CMD or CMD-SHELL, a string is the command line of CMD-SHELL
+type composeTest []string
+
+func (t *composeTest) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = composeTest{"CMD-SHELL", value.Value}
+		return nil
+	}
+	var test []string
+	if err := value.Decode(&test); err != nil {
+		return err
+	}
+	*t = test
+	return nil
+}
+
+// commandLine returns the shell command line of the health check, empty if it is disabled
+// The words of the CMD form are quoted so that the shell runs the same command
+func (t composeTest) commandLine() string {
+	if len(t) == 0 || t[0] == "NONE" {
+		return ""
+	}
+	if t[0] == "CMD-SHELL" {
+		return strings.Join(t[1:], " ")
+	}
+	words := t
+	if t[0] == "CMD" {
+		words = t[1:]
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafeRegexp = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// shellQuote returns a word quoted for the shell, the words without special characters are kept as they are
+func shellQuote(word string) string {
+	if shellSafeRegexp.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// splitCommandLine splits a command line into words as a POSIX shell does, with its quotes and backslashes but without expanding anything
+// It returns an error if a quote is not closed or the line ends with a backslash
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 == len(line) {
+				return nil, fmt.Errorf("the command line %q ends with a backslash", line)
+			}
+			i++
+			word.WriteByte(line[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("the command line %q has an unterminated quote", line)
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				// In double quotes a backslash only escapes the characters that are special there
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("$`\"\\\n", line[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("the command line %q has an unterminated quote", line)
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// composeEnv is a list of environment variables written as a list of KEY=value or as a mapping
+type composeEnv []string
+
+func (e *composeEnv) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		var env []string
+		if err := value.Decode(&env); err != nil {
+			return err
+		}
+		*e = env
+		return nil
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		*e = append(*e, value.Content[i].Value+"="+value.Content[i+1].Value)
+	}
+	return nil
+}
+
+// composeDependency is the condition of a dependency of a service
+type composeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+// composeHealthcheck is the health check of a service
+type composeHealthcheck struct {
+	Test        composeTest `yaml:"test"`
+	Interval    string      `yaml:"interval,omitempty"`
+	Timeout     string      `yaml:"timeout,omitempty"`
+	Retries     int         `yaml:"retries,omitempty"`
+	StartPeriod string      `yaml:"start_period,omitempty"`
+}
+
+// composeUlimit is the soft and hard limit of a resource, a single number sets both
+type composeUlimit struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
+func (u *composeUlimit) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var limit int64
+		if err := value.Decode(&limit); err != nil {
+			return err
+		}
+		u.Soft, u.Hard = limit, limit
+		return nil
+	}
+	type plain composeUlimit
+	return value.Decode((*plain)(u))
+}
+
+// composeService is the subset of the service settings that ContainMesh can express
+type composeService struct {
+	Image         string                       `yaml:"image,omitempty"`
+	ContainerName string                       `yaml:"container_name,omitempty"`
+	Command       composeCommand               `yaml:"command,omitempty"`
+	Environment   composeEnv                   `yaml:"environment,omitempty"`
+	User          string                       `yaml:"user,omitempty"`
+	Networks      composeNames                 `yaml:"networks,omitempty"`
+	DependsOn     map[string]composeDependency `yaml:"depends_on,omitempty"`
+	Healthcheck   *composeHealthcheck          `yaml:"healthcheck,omitempty"`
+	Privileged    bool                         `yaml:"privileged,omitempty"`
+	ReadOnly      bool                         `yaml:"read_only,omitempty"`
+	CapAdd        []string                     `yaml:"cap_add,omitempty"`
+	CapDrop       []string                     `yaml:"cap_drop,omitempty"`
+	SecurityOpt   []string                     `yaml:"security_opt,omitempty"`
+	CPUShares     int64                        `yaml:"cpu_shares,omitempty"`
+	CPUQuota      int64                        `yaml:"cpu_quota,omitempty"`
+	CPUPeriod     int64                        `yaml:"cpu_period,omitempty"`
+	Cpuset        string                       `yaml:"cpuset,omitempty"`
+	MemLimit      string                       `yaml:"mem_limit,omitempty"`
+	MemswapLimit  string                       `yaml:"memswap_limit,omitempty"`
+	PidsLimit     int64                        `yaml:"pids_limit,omitempty"`
+	BlkioConfig   *struct {
+		Weight uint16 `yaml:"weight,omitempty"`
+	} `yaml:"blkio_config,omitempty"`
+	Ulimits map[string]composeUlimit `yaml:"ulimits,omitempty"`
+	Labels  map[string]string        `yaml:"labels,omitempty"`
+	ignored []string                 // Keys of the file that ContainMesh can't express, e.g. ports or deploy.resources
+}
+
+// composeServiceKeys are the keys of a service that are read
+var composeServiceKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(composeService{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("yaml"); tag != "" {
+			keys[strings.Split(tag, ",")[0]] = true
+		}
+	}
+	return keys
+}()
+
+// UnmarshalYAML reads a service, its dependencies can be written as a list or as a mapping
+// The keys that are not read are kept to be reported, the ones of deploy are listed one by one
+func (s *composeService) UnmarshalYAML(value *yaml.Node) error {
+	// The dependencies are decoded apart since they can be a list
+	type plain composeService
+	var dependsOn *yaml.Node
+	var ignored []string
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, content := value.Content[i].Value, value.Content[i+1]
+		switch {
+		case key == "depends_on" && content.Kind == yaml.SequenceNode:
+			dependsOn = content
+			value.Content = append(value.Content[:i:i], value.Content[i+2:]...)
+			i -= 2
+		case key == "deploy" && content.Kind == yaml.MappingNode:
+			for j := 0; j < len(content.Content); j += 2 {
+				ignored = append(ignored, "deploy."+content.Content[j].Value)
+			}
+		case !composeServiceKeys[key]:
+			ignored = append(ignored, key)
+		}
+	}
+	if err := value.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.ignored = ignored
+	if dependsOn != nil {
+		var names []string
+		if err := dependsOn.Decode(&names); err != nil {
+			return err
+		}
+		s.DependsOn = map[string]composeDependency{}
+		for _, name := range names {
+			s.DependsOn[name] = composeDependency{Condition: "service_started"}
+		}
+	}
+	return nil
+}
+
+// composeNetwork is a network of a compose file
+type composeNetwork struct {
+	Name   string `yaml:"name,omitempty"`
+	Driver string `yaml:"driver,omitempty"`
+}
+
+// composeFile is the subset of a compose file that ContainMesh can express
+type composeFile struct {
+	Name     string                     `yaml:"name,omitempty"`
+	Services map[string]*composeService `yaml:"services"`
+	Networks map[string]*composeNetwork `yaml:"networks,omitempty"`
+}
+
+var composeNameRegexp = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// composeProjectName returns a valid compose project name derived from a name
+func composeProjectName(name string) string {
+	name = strings.Trim(composeNameRegexp.ReplaceAllString(strings.ToLower(name), "-"), "-_")
+	if name == "" {
+		return "containmesh"
+	}
+	return name
+}
+
+var nameNumberRegexp = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// compareNames orders the names by prefix then by number, so that node10 comes after node2
+func compareNames(a string, b string) int {
+	ma, mb := nameNumberRegexp.FindStringSubmatch(a), nameNumberRegexp.FindStringSubmatch(b)
+	if ma == nil || mb == nil || ma[1] != mb[1] {
+		return strings.Compare(a, b)
+	}
+	na, _ := strconv.Atoi(ma[2])
+	nb, _ := strconv.Atoi(mb[2])
+	return na - nb
+}
+
+// composeDuration formats a duration of a health check, empty if it is not set
+func composeDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// NewComposeFile converts the expanded configuration into a compose file: a network per network, a service per node joining its network and the networks it links, with its image, command, environment, health check, security profile, resources and dependencies
+// It returns an error if the settings of a node are not valid
+func NewComposeFile(config *config.Config) (*composeFile, error) {
+	compose := &composeFile{
+		Name:     composeProjectName(containerNamePrefix(config.MeshName())),
+		Services: map[string]*composeService{},
+		Networks: map[string]*composeNetwork{},
+	}
+	for network := 0; network < *config.NumNetworks; network++ {
+		name := networkName(config, network)
+		compose.Networks[name] = &composeNetwork{Name: name, Driver: "bridge"}
+	}
+	dependencies, err := config.NodeDependencies()
+	if err != nil {
+		return nil, err
+	}
+	links := Links(config)
+	for node := 0; node < config.TotalNodes(); node++ {
+		name := ContainerNameFromNodeNumber(node, config.MeshName())
+		containerConfig := NodeContainerConfig(config, node)
+		hostConfig, err := NodeHostConfig(config, node)
+		if err != nil {
+			return nil, err
+		}
+		service := &composeService{
+			Image:         containerConfig.Image,
+			ContainerName: name,
+			Command:       composeCommand(containerConfig.Cmd),
+			Environment:   containerConfig.Env,
+			Labels:        containerConfig.Labels,
+			User:          containerConfig.User,
+			Networks:      composeNames{networkName(config, node / *config.NumContainers)},
+			Privileged:    hostConfig.Privileged,
+			ReadOnly:      hostConfig.ReadonlyRootfs,
+			CapAdd:        hostConfig.CapAdd,
+			CapDrop:       hostConfig.CapDrop,
+			CPUShares:     hostConfig.CPUShares,
+			CPUQuota:      hostConfig.CPUQuota,
+			CPUPeriod:     hostConfig.CPUPeriod,
+			Cpuset:        hostConfig.CpusetCpus,
+		}
+		for _, link := range links {
+			if link.Node == node {
+				service.Networks = append(service.Networks, networkName(config, link.To))
+			}
+		}
+		for _, option := range hostConfig.SecurityOpt {
+			// The compose file refers to the seccomp profile by path, as the command line
+			if strings.HasPrefix(option, "seccomp=") && config.Security.SeccompProfile != "unconfined" {
+				option = "seccomp=" + config.Security.SeccompProfile
+			}
+			service.SecurityOpt = append(service.SecurityOpt, option)
+		}
+		if hostConfig.Memory != 0 {
+			service.MemLimit = strconv.FormatInt(hostConfig.Memory, 10)
+		}
+		if hostConfig.MemorySwap != 0 {
+			service.MemswapLimit = strconv.FormatInt(hostConfig.MemorySwap, 10)
+		}
+		if hostConfig.PidsLimit != nil {
+			service.PidsLimit = *hostConfig.PidsLimit
+		}
+		if hostConfig.BlkioWeight != 0 {
+			service.BlkioConfig = &struct {
+				Weight uint16 `yaml:"weight,omitempty"`
+			}{hostConfig.BlkioWeight}
+		}
+		for _, ulimit := range hostConfig.Ulimits {
+			if service.Ulimits == nil {
+				service.Ulimits = map[string]composeUlimit{}
+			}
+			service.Ulimits[ulimit.Name] = composeUlimit{Soft: ulimit.Soft, Hard: ulimit.Hard}
+		}
+		if health := containerConfig.Healthcheck; health != nil {
+			service.Healthcheck = &composeHealthcheck{Test: composeTest(health.Test), Interval: composeDuration(health.Interval), Timeout: composeDuration(health.Timeout),
+				Retries: health.Retries, StartPeriod: composeDuration(health.StartPeriod)}
+		}
+		for _, dependency := range dependencies[node] {
+			if service.DependsOn == nil {
+				service.DependsOn = map[string]composeDependency{}
+			}
+			// A dependency is ready when it is healthy, or started if it has no health check
+			condition := "service_started"
+			if config.HealthCheckForNode(dependency).Command != "" {
+				condition = "service_healthy"
+			}
+			service.DependsOn[ContainerNameFromNodeNumber(dependency, config.MeshName())] = composeDependency{Condition: condition}
+		}
+		compose.Services[name] = service
+	}
+	return compose, nil
+}
+
+// WriteCompose writes the expanded configuration as a docker-compose file
+// It returns an error if the settings of a node are not valid or the file can't be written
+func WriteCompose(w io.Writer, config *config.Config) error {
+	compose, err := NewComposeFile(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# Generated by ContainMesh from the configuration of %d nodes in %d networks\n", config.TotalNodes(), *config.NumNetworks)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(compose); err != nil {
+		return fmt.Errorf("error encoding the compose file: %v", err)
+	}
+	return encoder.Close()
+}
+
+// parseComposeDuration parses a duration of a compose file, 0 if it is empty
+func parseComposeDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ImportCompose builds a ContainMesh configuration from the services and the networks of a compose file
+// Every service becomes a node of the first network it joins, the services that join more networks are the bridge nodes of their network and the networks are linked as the services join them
+// The settings that ContainMesh can't express exactly are approximated and returned as warnings
+// It returns an error if the compose file can't be read or a setting is not valid
+func ImportCompose(path string) (*config.YamlConfig, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading the compose file: %v", err)
+	}
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, nil, fmt.Errorf("error decoding the compose file: %v", err)
+	}
+	if len(compose.Services) == 0 {
+		return nil, nil, fmt.Errorf("the compose file has no services")
+	}
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	// The networks declared in the file come first, the services without networks join the default one
+	var networks []string
+	for name := range compose.Networks {
+		networks = append(networks, name)
+	}
+	slices.SortFunc(networks, compareNames)
+	var services []string
+	for name, service := range compose.Services {
+		services = append(services, name)
+		if len(service.Networks) == 0 {
+			service.Networks = composeNames{"default"}
+		}
+		for _, network := range service.Networks {
+			if !slices.Contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	slices.SortFunc(services, compareNames)
+
+	// Every network gets its services, the bridge ones first
+	members := make([][]string, len(networks))
+	bridges := make([]int, len(networks))
+	for _, name := range services {
+		home := slices.Index(networks, compose.Services[name].Networks[0])
+		if len(compose.Services[name].Networks) > 1 {
+			members[home] = slices.Insert(members[home], bridges[home], name)
+			bridges[home]++
+		} else {
+			members[home] = append(members[home], name)
+		}
+	}
+	numContainers, numLinks := 1, 1
+	for i := range networks {
+		numContainers = max(numContainers, len(members[i]))
+		numLinks = max(numLinks, bridges[i])
+	}
+	matrix := make([][]bool, len(networks))
+	for i := range matrix {
+		matrix[i] = make([]bool, len(networks))
+	}
+	for _, name := range services {
+		joined := compose.Services[name].Networks
+		home := slices.Index(networks, joined[0])
+		for _, network := range joined[1:] {
+			matrix[home][slices.Index(networks, network)] = true
+		}
+	}
+	for i := range networks {
+		if len(members[i]) < numContainers {
+			warn("the network %s is completed with %d nodes running the default command, every network has %d nodes", networks[i], numContainers-len(members[i]), numContainers)
+		}
+		for k := bridges[i]; k < min(numLinks, len(members[i])) && slices.Contains(matrix[i], true); k++ {
+			warn("the service %s becomes a bridge node of the network %s, every network has %d bridge nodes", members[i][k], networks[i], numLinks)
+		}
+		for k := 0; k < bridges[i]; k++ {
+			joined := compose.Services[members[i][k]].Networks
+			for j := range networks {
+				if matrix[i][j] && !slices.Contains(joined, networks[j]) {
+					warn("the service %s also joins the network %s, the bridge nodes join every network linked to their own", members[i][k], networks[j])
+				}
+			}
+		}
+	}
+
+	// The most used image is the main one, the other images are set by the node groups
+	images := map[string]int{}
+	for _, name := range services {
+		images[compose.Services[name].Image]++
+	}
+	mainImage := ""
+	for image, count := range images {
+		if image != "" && (count > images[mainImage] || count == images[mainImage] && image < mainImage) {
+			mainImage = image
+		}
+	}
+	if mainImage == "" {
+		return nil, nil, fmt.Errorf("the services of the compose file have no image")
+	}
+
+	yamlConf := &config.YamlConfig{}
+	yamlConf.ImageSettings.ImageName = mainImage
+	yamlConf.ImageSettings.IgnoreBuild = true
+	project := compose.Name
+	if project == "" {
+		project = filepath.Base(filepath.Dir(path))
+	}
+	yamlConf.NetworkSettings.NetworkName = composeProjectName(project) + "_net"
+	// The names of the networks are kept if they are already numbered as ContainMesh does
+	if match := nameNumberRegexp.FindStringSubmatch(networks[0]); match != nil && match[2] == "0" {
+		yamlConf.NetworkSettings.NetworkName = match[1]
+	}
+	for i, network := range networks {
+		if network != yamlConf.NetworkSettings.NetworkName+strconv.Itoa(i) {
+			if len(networks) == 1 {
+				warn("the network %s is renamed %s0", network, yamlConf.NetworkSettings.NetworkName)
+			} else {
+				warn("the networks are renamed %s0 to %s%d", yamlConf.NetworkSettings.NetworkName, yamlConf.NetworkSettings.NetworkName, len(networks)-1)
+			}
+			break
+		}
+	}
+	yamlConf.NetworkSettings.NumNetworks = len(networks)
+	yamlConf.NetworkSettings.NumContainers = numContainers
+	yamlConf.NetworkSettings.NumLinks = numLinks
+	yamlConf.NetworkSettings.NetMatrix = matrix
+
+	for i := range networks {
+		for k, name := range members[i] {
+			service := compose.Services[name]
+			for _, key := range service.ignored {
+				warn("the key %s of the service %s is ignored, ContainMesh can't express it", key, name)
+			}
+			group := config.NodeGroup{Name: name, Nodes: strconv.Itoa(i*numContainers + k), Command: service.Command, Env: service.Environment}
+			if service.Image != mainImage {
+				group.Image = service.Image
+			}
+			group.Resources = config.ResourceSettings{CPUQuota: service.CPUQuota, CPUPeriod: service.CPUPeriod, CPUShares: service.CPUShares,
+				CPUSetCPUs: service.Cpuset, Memory: service.MemLimit, MemorySwap: service.MemswapLimit, PidsLimit: service.PidsLimit}
+			if service.BlkioConfig != nil {
+				group.Resources.BlkioWeight = service.BlkioConfig.Weight
+			}
+			for name, ulimit := range service.Ulimits {
+				group.Resources.Ulimits = append(group.Resources.Ulimits, config.UlimitSettings{Name: name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+			}
+			if err := group.Resources.Validate(); err != nil {
+				return nil, nil, fmt.Errorf("error in the resources of the service %s: %v", name, err)
+			}
+			if health := service.Healthcheck; health != nil && health.Test.commandLine() != "" {
+				group.HealthCheck = config.HealthCheckSettings{Command: health.Test.commandLine(), Retries: health.Retries}
+				durations := []*time.Duration{&group.HealthCheck.Interval, &group.HealthCheck.Timeout, &group.HealthCheck.StartPeriod}
+				for j, value := range []string{health.Interval, health.Timeout, health.StartPeriod} {
+					if *durations[j], err = parseComposeDuration(value); err != nil {
+						return nil, nil, fmt.Errorf("error in the health check of the service %s: %v", name, err)
+					}
+				}
+			}
+			for dependency := range service.DependsOn {
+				group.DependsOn = append(group.DependsOn, dependency)
+			}
+			sort.Strings(group.DependsOn)
+			security := composeSecurity(service)
+			if len(yamlConf.NodeGroups) == 0 {
+				yamlConf.SecuritySettings = security
+			} else if !reflect.DeepEqual(security, yamlConf.SecuritySettings) {
+				// The security profile is the same for every node, the services get the most permissive one
+				warn("the security settings of the service %s differ from the other services, the most permissive ones are applied to every node", name)
+				merged := &yamlConf.SecuritySettings
+				merged.Privileged = merged.Privileged || security.Privileged
+				merged.NoNewPrivileges = merged.NoNewPrivileges && security.NoNewPrivileges
+				merged.ReadOnlyRootfs = merged.ReadOnlyRootfs && security.ReadOnlyRootfs
+				for _, capability := range security.CapAdd {
+					if !slices.Contains(merged.CapAdd, capability) {
+						merged.CapAdd = append(merged.CapAdd, capability)
+					}
+				}
+				merged.CapDrop = slices.DeleteFunc(merged.CapDrop, func(capability string) bool { return !slices.Contains(security.CapDrop, capability) })
+				if security.SeccompProfile == "unconfined" || merged.SeccompProfile == "" {
+					merged.SeccompProfile = security.SeccompProfile
+				}
+				if security.AppArmorProfile == "unconfined" || merged.AppArmorProfile == "" {
+					merged.AppArmorProfile = security.AppArmorProfile
+				}
+				if merged.User != security.User {
+					merged.User = ""
+				}
+			}
+			yamlConf.NodeGroups = append(yamlConf.NodeGroups, group)
+		}
+	}
+	return yamlConf, warnings, nil
+}
+
+// composeSecurity returns the security settings of a service, the capabilities of the default profile are left out
+func composeSecurity(service *composeService) config.SecuritySettings {
+	security := config.SecuritySettings{Privileged: service.Privileged, ReadOnlyRootfs: service.ReadOnly, User: service.User}
+	for _, capability := range service.CapAdd {
+		if !slices.Contains(config.DefaultCapabilities, capability) {
+			security.CapAdd = append(security.CapAdd, capability)
+		}
+	}
+	for _, capability := range service.CapDrop {
+		if slices.Contains(config.DefaultCapabilities, capability) {
+			security.CapDrop = append(security.CapDrop, capability)
+		}
+	}
+	for _, option := range service.SecurityOpt {
+		option = strings.Replace(option, ":", "=", 1)
+		if value, ok := strings.CutPrefix(option, "apparmor="); ok {
+			security.AppArmorProfile = value
+		} else if value, ok := strings.CutPrefix(option, "seccomp="); ok {
+			security.SeccompProfile = value
+		} else if option == "no-new-privileges" || option == "no-new-privileges=true" {
+			security.NoNewPrivileges = true
+		}
+	}
+	return security
+}
+
+// WriteImportedCompose writes the configuration built from a compose file as a ContainMesh yaml file, the warnings are written as comments
+// It returns an error if the configuration can't be encoded or written
+func WriteImportedCompose(w io.Writer, source string, yamlConf *config.YamlConfig, warnings []string) error {
+	fmt.Fprintf(w, "# Generated by ContainMesh from %s\n", source)
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "# Warning: %s\n", warning)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(yamlConf); err != nil {
+		return fmt.Errorf("error encoding the configuration: %v", err)
+	}
+	return encoder.Close()
+}
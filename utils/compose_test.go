@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportComposeCommands(t *testing.T) {
+	tests := []struct {
+		name        string
+		service     string
+		command     []string
+		healthCheck string
+	}{
+		{
+			name:    "command as a list",
+			service: `command: ["sh", "-c", "echo a b"]`,
+			command: []string{"sh", "-c", "echo a b"},
+		},
+		{
+			name:    "command as a string is split without a shell",
+			service: `command: nginx -g 'daemon off;' -c "/etc/my conf" a\ b $HOME`,
+			command: []string{"nginx", "-g", "daemon off;", "-c", "/etc/my conf", "a b", "$HOME"},
+		},
+		{
+			name: "health check as a string",
+			service: `healthcheck:
+      test: curl -f "http://localhost/a b" || exit 1`,
+			healthCheck: `curl -f "http://localhost/a b" || exit 1`,
+		},
+		{
+			name: "health check in the CMD-SHELL form",
+			service: `healthcheck:
+      test: ["CMD-SHELL", "pg_isready -U 'my user'"]`,
+			healthCheck: `pg_isready -U 'my user'`,
+		},
+		{
+			name: "health check in the CMD form keeps its words",
+			service: `healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost/a b", "it's"]`,
+			healthCheck: `curl -f 'http://localhost/a b' 'it'\''s'`,
+		},
+		{
+			name: "health check disabled",
+			service: `healthcheck:
+      test: ["NONE"]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "docker-compose.yml")
+			content := "services:\n  web:\n    image: nginx\n    " + test.service + "\n"
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			yamlConf, _, err := ImportCompose(path)
+			if err != nil {
+				t.Fatalf("ImportCompose() error = %v", err)
+			}
+			group := yamlConf.NodeGroups[0]
+			if !reflect.DeepEqual(group.Command, test.command) {
+				t.Errorf("Command = %q, want %q", group.Command, test.command)
+			}
+			if group.HealthCheck.Command != test.healthCheck {
+				t.Errorf("HealthCheck.Command = %q, want %q", group.HealthCheck.Command, test.healthCheck)
+			}
+		})
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		words   []string
+		wantErr bool
+	}{
+		{line: "  echo   hello  ", words: []string{"echo", "hello"}},
+		{line: `a'b c'"d e"`, words: []string{"ab cd e"}},
+		{line: `"a \"b\" \c" ''`, words: []string{`a "b" \c`, ""}},
+		{line: `echo 'unterminated`, wantErr: true},
+		{line: `echo "unterminated`, wantErr: true},
+		{line: `echo \`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			words, err := splitCommandLine(test.line)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("splitCommandLine() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(words, test.words) {
+				t.Errorf("splitCommandLine() = %q, want %q", words, test.words)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+)
+
+// ExpandNodeTemplate replaces the placeholders of a path with the values of a node: {node} (node number), {name} (container name) and {network} (network number)
+func ExpandNodeTemplate(path string, config *config.Config, nodeNumber int) string {
+	return strings.NewReplacer(
+		"{node}", strconv.Itoa(nodeNumber),
+		"{name}", ContainerNameFromNodeNumber(nodeNumber, config.MeshName()),
+		"{network}", strconv.Itoa(nodeNumber / *config.NumContainers),
+	).Replace(path)
+}
+
+// CopyToNode copies a local file or directory into a directory of a node, the same way GetContext tars the build context
+// It returns an error if the source can't be archived or the copy fails
+func CopyToNode(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, src string, dstDir string) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationCopyIn, nodeTarget(nodeNumber), src+" -> "+dstDir, start, err) }()
+	src = filepath.Clean(src)
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", src, err)
+	}
+	// Archive the source with its base name so that it keeps its name in the destination
+	content, err := archive.TarWithOptions(filepath.Dir(src), &archive.TarOptions{IncludeFiles: []string{filepath.Base(src)}})
+	if err != nil {
+		return fmt.Errorf("error archiving %s: %v", src, err)
+	}
+	defer content.Close()
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	err = cli.CopyToContainer(ctx, containerName, dstDir, content, container.CopyToContainerOptions{})
+	if err != nil {
+		return fmt.Errorf("error copying %s into the container %d: %v", src, nodeNumber, err)
+	}
+	kind := "File"
+	if info.IsDir() {
+		kind = "Directory"
+	}
+	logf("%s %s copied into %s of container %d\n", kind, src, dstDir, nodeNumber)
+	return nil
+}
+
+// CopyFromNode copies a file or directory of a node into a local directory, which is created if it doesn't exist
+// It returns an error if the copy or the extraction fails
+func CopyFromNode(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, src string, dstDir string) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationCopyOut, nodeTarget(nodeNumber), src+" -> "+dstDir, start, err) }()
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	content, _, err := cli.CopyFromContainer(ctx, containerName, src)
+	if err != nil {
+		return fmt.Errorf("error copying %s from the container %d: %v", src, nodeNumber, err)
+	}
+	defer content.Close()
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return fmt.Errorf("error creating the directory %s: %v", dstDir, err)
+	}
+	// The files keep the owner of the user running ContainMesh
+	err = archive.Untar(content, dstDir, &archive.TarOptions{NoLchown: true})
+	if err != nil {
+		return fmt.Errorf("error extracting %s of the container %d: %v", src, nodeNumber, err)
+	}
+	logf("%s of container %d copied into %s\n", src, nodeNumber, dstDir)
+	return nil
+}
+
+// CopyToNodes copies a local path into a directory of every node concurrently, the local path can contain the node placeholders of ExpandNodeTemplate
+// It returns the errors of all the nodes where the copy fails
+func CopyToNodes(ctx context.Context, cli *client.Client, config *config.Config, nodes []int, src string, dstDir string) error {
+	return forNodes(nodes, func(node int) error {
+		return CopyToNode(ctx, cli, node, config.MeshName(), ExpandNodeTemplate(src, config, node), dstDir)
+	})
+}
+
+// CopyFromNodes copies a path of every node concurrently into a local directory, which should contain a node placeholder of ExpandNodeTemplate to keep the copies apart
+// It returns the errors of all the nodes where the copy fails
+func CopyFromNodes(ctx context.Context, cli *client.Client, config *config.Config, nodes []int, src string, dstDir string) error {
+	if len(nodes) > 1 && !strings.Contains(dstDir, "{node}") && !strings.Contains(dstDir, "{name}") {
+		return fmt.Errorf("the destination %s must contain {node} or {name} when copying from more than one node", dstDir)
+	}
+	return forNodes(nodes, func(node int) error {
+		return CopyFromNode(ctx, cli, node, config.MeshName(), src, ExpandNodeTemplate(dstDir, config, node))
+	})
+}
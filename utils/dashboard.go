@@ -0,0 +1,502 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dashboardRefresh   = 2 * time.Second // Interval between two refreshes of the node table
+	dashboardMaxEvents = 500             // Lines kept in the event log
+	dashboardPaneLines = 10              // Height of the network and event panes
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	paneStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("241")).Padding(0, 1)
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// dashboardNode is a row of the node table
+type dashboardNode struct {
+	NodeStatus
+	IP       string
+	Networks []int
+	Stats    *NodeStats // Last resource usage, nil if the node is not running
+}
+
+// nodesMsg carries the nodes and the state read by a refresh
+type nodesMsg struct {
+	nodes []dashboardNode
+	state *MeshState
+	err   error
+}
+
+// refreshMsg asks the dashboard to refresh the node table
+type refreshMsg struct{}
+
+// eventMsg is a line of the event log
+type eventMsg string
+
+// actionMsg reports the end of an action started from the dashboard
+type actionMsg struct {
+	name string
+	err  error
+}
+
+// formMsg opens an input form
+type formMsg struct {
+	form *dashboardForm
+}
+
+// dashboardForm asks the user for a value, submit is called with the entered value
+type dashboardForm struct {
+	title  string
+	input  textinput.Model
+	submit func(value string) tea.Cmd
+}
+
+// newDashboardForm creates a form given its title, the placeholder and the initial value of the input
+func newDashboardForm(title string, placeholder string, value string, submit func(value string) tea.Cmd) *dashboardForm {
+	input := textinput.New()
+	input.Placeholder = placeholder
+	input.SetValue(value)
+	input.Focus()
+	return &dashboardForm{title: title, input: input, submit: submit}
+}
+
+// eventWriter sends every line written to it to the event log of the dashboard
+type eventWriter struct {
+	p       *tea.Program
+	mutex   sync.Mutex
+	partial string
+}
+
+func (w *eventWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.partial += string(b)
+	for {
+		i := strings.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		w.p.Send(eventMsg(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// fetchDashboardNodes reads the status and the addresses of every node, with the last resource usage sampled by the collector
+// It returns an error if the status of the nodes can't be retrieved
+func fetchDashboardNodes(cli *client.Client, config *config.Config, collector *StatsCollector) ([]dashboardNode, *MeshState, error) {
+	statuses, err := GetNodesStatus(cli, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return nil, nil, err
+	}
+	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", containerNamePrefix(config.MeshName()))),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := make([]dashboardNode, len(statuses))
+	for i, status := range statuses {
+		nodes[i].NodeStatus = status
+	}
+	for _, c := range containers {
+		if c.NetworkSettings == nil || len(c.Names) == 0 {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(c.Names[0], "/"+containerNamePrefix(config.MeshName())))
+		if err != nil || number < 0 || number >= len(nodes) {
+			continue
+		}
+		for name, endpoint := range c.NetworkSettings.Networks {
+			network, err := strconv.Atoi(strings.TrimPrefix(name, config.NetworkPrefix()))
+			if err != nil {
+				continue
+			}
+			nodes[number].Networks = append(nodes[number].Networks, network)
+			if network == number / *config.NumContainers {
+				nodes[number].IP = endpoint.IPAddress
+			}
+		}
+		sort.Ints(nodes[number].Networks)
+	}
+	for i := range nodes {
+		if stats, ok := collector.Latest(i); ok && nodes[i].State == NodeRunning {
+			nodes[i].Stats = &stats
+		}
+	}
+	return nodes, state, nil
+}
+
+// dashboard is the live view of the virtual environment
+type dashboard struct {
+	cli      *client.Client
+	config   *config.Config
+	stats    *StatsCollector
+	events   *eventWriter
+	table    table.Model
+	nodes    []dashboardNode
+	state    *MeshState
+	log      []string
+	form     *dashboardForm
+	err      error
+	fetching bool
+	width    int
+}
+
+func newDashboard(cli *client.Client, config *config.Config, stats *StatsCollector, events *eventWriter) dashboard {
+	t := table.New(
+		table.WithColumns([]table.Column{
+			{Title: "Node", Width: 5},
+			{Title: "State", Width: 12},
+			{Title: "Health/Fault", Width: 26},
+			{Title: "IP", Width: 15},
+			{Title: "Networks", Width: 10},
+			{Title: "CPU", Width: 7},
+			{Title: "Memory", Width: 20},
+			{Title: "Net rx/tx", Width: 22},
+		}),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.BorderStyle(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color("241")).BorderBottom(true).Bold(true)
+	styles.Selected = styles.Selected.Foreground(lipgloss.Color("229")).Background(lipgloss.Color("57"))
+	t.SetStyles(styles)
+	return dashboard{cli: cli, config: config, stats: stats, events: events, table: t, state: &MeshState{}}
+}
+
+// Init sends the first refresh, the fetch is started from Update so that the model keeps its fetching flag
+func (m dashboard) Init() tea.Cmd {
+	return func() tea.Msg { return refreshMsg{} }
+}
+
+// tickRefresh schedules the next refresh
+func tickRefresh() tea.Cmd {
+	return tea.Tick(dashboardRefresh, func(time.Time) tea.Msg { return refreshMsg{} })
+}
+
+// fetch reads the nodes in background
+func (m *dashboard) fetch() tea.Cmd {
+	if m.fetching {
+		return nil
+	}
+	m.fetching = true
+	cli, config, stats := m.cli, m.config, m.stats
+	return func() tea.Msg {
+		nodes, state, err := fetchDashboardNodes(cli, config, stats)
+		return nodesMsg{nodes, state, err}
+	}
+}
+
+// selected returns the node selected in the table
+func (m dashboard) selected() int {
+	return m.table.Cursor()
+}
+
+// action runs an operation in background and reports its outcome to the event log
+func action(name string, run func() error) tea.Cmd {
+	return func() tea.Msg {
+		return actionMsg{name, run()}
+	}
+}
+
+// openForm returns a command that opens a form
+func openForm(form *dashboardForm) tea.Cmd {
+	return func() tea.Msg { return formMsg{form} }
+}
+
+func (m dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		// Title, help line, table header and the two bordered panes
+		m.table.SetHeight(max(msg.Height-dashboardPaneLines-8, 3))
+		return m, nil
+	case refreshMsg:
+		return m, tea.Batch(m.fetch(), tickRefresh())
+	case nodesMsg:
+		m.fetching = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.nodes, m.state = msg.nodes, msg.state
+			m.table.SetRows(m.rows())
+		}
+		return m, nil
+	case eventMsg:
+		m.appendEvent(string(msg))
+		return m, nil
+	case actionMsg:
+		if msg.err != nil {
+			m.appendEvent(errorStyle.Render(fmt.Sprintf("%s: %v", msg.name, msg.err)))
+		}
+		return m, m.fetch()
+	case formMsg:
+		m.form = msg.form
+		return m, textinput.Blink
+	case tea.KeyMsg:
+		if m.form != nil {
+			return m.updateForm(msg)
+		}
+		if cmd, ok := m.handleKey(msg); ok {
+			return m, cmd
+		}
+	}
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+// updateForm passes a key to the open form
+func (m dashboard) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.form = nil
+		return m, nil
+	case "enter":
+		form := m.form
+		m.form = nil
+		return m, form.submit(strings.TrimSpace(form.input.Value()))
+	}
+	var cmd tea.Cmd
+	m.form.input, cmd = m.form.input.Update(msg)
+	return m, cmd
+}
+
+// handleKey runs the action bound to a key
+// It returns false if the key is not bound to an action
+func (m dashboard) handleKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	cli, cfg := m.cli, m.config
+	image := cfg.MeshName()
+	node := m.selected()
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit, true
+	case "s":
+		return action("stop", func() error { return StopContainer(cli, node, image) }), true
+	case "r":
+		return action("start", func() error { return RestartContainer(cli, node, image) }), true
+	case "p":
+		if node < len(m.nodes) && m.nodes[node].State == NodePaused {
+			return action("unpause", func() error { return UnpauseContainer(cli, node, image) }), true
+		}
+		return action("pause", func() error { return PauseContainer(cli, node, image) }), true
+	case "K":
+		return openForm(newDashboardForm(fmt.Sprintf("Signal to send to the node %d", node), "SIGKILL, SIGSTOP, SIGCONT", "SIGKILL", func(signal string) tea.Cmd {
+			return action("kill", func() error { return KillContainer(cli, node, image, signal) })
+		})), true
+	case "d":
+		return openForm(newDashboardForm(fmt.Sprintf("Restart the node %d after", node), "10s", "", func(text string) tea.Cmd {
+			return action("restart", func() error {
+				delay, err := time.ParseDuration(text)
+				if err != nil {
+					return fmt.Errorf("invalid delay %q", text)
+				}
+				return RestartContainerWithDelay(cli, node, image, delay)
+			})
+		})), true
+	case "l":
+		return openForm(newDashboardForm(fmt.Sprintf("Latency of the node %d (0 removes it)", node), "100ms", "", func(text string) tea.Cmd {
+			return action("latency", func() error {
+				latency, err := time.ParseDuration(text)
+				if err != nil {
+					return fmt.Errorf("invalid latency %q", text)
+				}
+				return SetLatency(cli, node, image, latency)
+			})
+		})), true
+	case "u":
+		return openForm(newDashboardForm(fmt.Sprintf("New resources of the node %d", node), "Memory: 256m, CPUShares: 512", "", func(text string) tea.Cmd {
+			return action("update resources", func() error {
+				var settings config.ResourceSettings
+				if err := yaml.Unmarshal([]byte("{"+text+"}"), &settings); err != nil {
+					return fmt.Errorf("invalid resources %q, they must be like Memory: 256m, CPUShares: 512", text)
+				}
+				return UpdateContainerResources(cli, node, image, settings)
+			})
+		})), true
+	case "e":
+		return openForm(m.execForm(strconv.Itoa(node))), true
+	case "E":
+		return openForm(newDashboardForm("Target of the command", "all, net:<network>, a node group or nodes like 0-4,7", "all", func(target string) tea.Cmd {
+			return openForm(m.execForm(target))
+		})), true
+	case "x":
+		network := node / *cfg.NumContainers
+		return openForm(newDashboardForm("Networks to partition", "0|1", fmt.Sprintf("%d|", network), func(pair string) tea.Cmd {
+			return action("partition", func() error {
+				network1, network2, err := ParseNetworkPair(cfg, pair)
+				if err != nil {
+					return err
+				}
+				return PartitionNetworks(cli, cfg, network1, network2)
+			})
+		})), true
+	case "h":
+		return action("heal", func() error {
+			_, err := runScenarioStep(context.Background(), cli, cfg, config.ScenarioStep{Action: "heal"})
+			return err
+		}), true
+	case "o":
+		events := m.events
+		return action("logs", func() error {
+			printer := &LogPrinter{Out: events}
+			return StreamLogs(context.Background(), cli, image, []int{node}, LogOptions{Tail: "20"}, printer.Print)
+		}), true
+	}
+	return nil, false
+}
+
+// execForm returns the form asking for a command to run on a target
+func (m dashboard) execForm(target string) *dashboardForm {
+	cli, cfg, events := m.cli, m.config, m.events
+	return newDashboardForm(fmt.Sprintf("Command to run on %s", target), "ip addr", "", func(commandLine string) tea.Cmd {
+		return action("exec", func() error {
+			nodes, err := cfg.ResolveTarget(target)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), defaultExecTimeout)
+			defer cancel()
+			results := ExecOnNodes(ctx, cli, cfg.MeshName(), nodes, ShellCommand(commandLine))
+			fmt.Fprintf(events, "$ %s\n", commandLine)
+			WriteExecResults(events, results)
+			return nil
+		})
+	})
+}
+
+// appendEvent adds a line to the event log, dropping the oldest lines
+func (m *dashboard) appendEvent(line string) {
+	m.log = append(m.log, time.Now().Format(time.TimeOnly)+" "+line)
+	if len(m.log) > dashboardMaxEvents {
+		m.log = m.log[len(m.log)-dashboardMaxEvents:]
+	}
+}
+
+// rows returns the rows of the node table
+func (m dashboard) rows() []table.Row {
+	rows := make([]table.Row, len(m.nodes))
+	for i, node := range m.nodes {
+		state := node.State
+		if node.State == NodeExited || node.State == NodeOOMKilled {
+			state += fmt.Sprintf(" (%d)", node.ExitCode)
+		}
+		details := node.Health
+		if node.Fault != nil {
+			if details != "" {
+				details += ", "
+			}
+			details += node.Fault.String()
+		}
+		networks := make([]string, len(node.Networks))
+		for j, network := range node.Networks {
+			networks[j] = strconv.Itoa(network)
+		}
+		var cpu, memory, network string
+		if node.Stats != nil {
+			cpu = fmt.Sprintf("%.1f%%", node.Stats.CPU)
+			memory = units.BytesSize(float64(node.Stats.Memory)) + " / " + units.BytesSize(float64(node.Stats.MemoryLimit))
+			network = rate(node.Stats.NetRxRate) + " " + rate(node.Stats.NetTxRate)
+		}
+		rows[i] = table.Row{strconv.Itoa(node.Node), state, details, node.IP, strings.Join(networks, ","), cpu, memory, network}
+	}
+	return rows
+}
+
+// networksView renders the networks with their links and the link faults
+func (m dashboard) networksView() []string {
+	linked := map[int][]string{}
+	for _, link := range Links(m.config) {
+		linked[link.From] = append(linked[link.From], fmt.Sprintf("%d:%d", link.Node, link.To))
+	}
+	var lines []string
+	for network := 0; network < *m.config.NumNetworks; network++ {
+		nodes, _ := m.config.NetworkNodes(network)
+		line := fmt.Sprintf("%s%d  nodes %s", m.config.NetworkPrefix(), network, config.FormatNodeSelection(nodes))
+		if len(linked[network]) > 0 {
+			line += "  links " + strings.Join(linked[network], " ")
+		}
+		lines = append(lines, line)
+	}
+	for _, fault := range m.state.LinkFaults {
+		links := make([]string, len(fault.Links))
+		for i, link := range fault.Links {
+			links[i] = fmt.Sprintf("%d:%d", link.Node, link.To)
+		}
+		lines = append(lines, unhealthyStyle.Render(fmt.Sprintf("%s %s since %s", fault.Fault, strings.Join(links, " "), fault.Since.Format(time.TimeOnly))))
+	}
+	return lines
+}
+
+// pane renders the last lines that fit in a bordered pane
+func pane(title string, lines []string, width int) string {
+	height := dashboardPaneLines - 1
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	content := titleStyle.Render(title) + "\n" + strings.Join(lines, "\n")
+	return paneStyle.Width(width).Height(dashboardPaneLines).MaxHeight(dashboardPaneLines + 2).Render(content)
+}
+
+func (m dashboard) View() string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render(fmt.Sprintf("ContainMesh  %d nodes in %d networks", m.config.TotalNodes(), *m.config.NumNetworks)))
+	if m.err != nil {
+		s.WriteString("  " + errorStyle.Render(m.err.Error()))
+	}
+	s.WriteString("\n")
+	s.WriteString(m.table.View() + "\n")
+
+	width := max(m.width, 80)
+	networks := pane("Networks", m.networksView(), width*2/5-4)
+	events := pane("Events", m.log, width-width*2/5-4)
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, networks, events) + "\n")
+
+	if m.form != nil {
+		s.WriteString(m.form.title + ": " + m.form.input.View() + "  " + helpStyle.UnsetMargins().Render("(enter to confirm, esc to cancel)"))
+	} else {
+		s.WriteString(helpStyle.UnsetMargins().Render("↑/↓ select • s stop • r start • p pause/unpause • K kill • d delayed restart • l latency • u resources • e exec • E exec on target • x partition • h heal all • o logs • q quit"))
+	}
+	return s.String()
+}
+
+// Dashboard displays a live view of the virtual environment after its creation, with the actions on the nodes bound to keys
+// The resource usage of the nodes is read from the collector, that must be running
+// The messages of the operations are shown in its event log while it runs
+// It returns an error if the dashboard can't be displayed
+func Dashboard(config *config.Config, cli *client.Client, stats *StatsCollector) error {
+	events := &eventWriter{}
+	p := tea.NewProgram(newDashboard(cli, config, stats, events), tea.WithAltScreen())
+	events.p = p
+	previous := SetOutput(events)
+	defer SetOutput(previous)
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("error during the execution of the dashboard: %v", err)
+	}
+	return nil
+}
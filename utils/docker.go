@@ -2,19 +2,18 @@ package utils
 
 import (
 	"ContainMesh/config"
-	"bufio"
 	"context"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
-	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
@@ -23,19 +22,13 @@ import (
 	"github.com/moby/term"
 )
 
-var stoppedContainers []int // List of stopped containers
-
-// CreateNewContainer creates a new container given the image name, the container name, the network name and a pointer to a Docker client
+// CreateNewContainer creates a new container given the container name, the network name, the container and host configurations and a pointer to a Docker client
 // It returns the container ID and an error if the container creation fails
-func CreateNewContainer(image string, containerName string, networkName string, client *client.Client, p *tea.Program) (string, error) {
+func CreateNewContainer(containerName string, networkName string, containerConfig *container.Config, hostConfig *container.HostConfig, client *client.Client, p *tea.Program) (id string, err error) {
 	start := time.Now()
-	resp, err := client.ContainerCreate(context.Background(), &container.Config{
-		Image: image,
-		Cmd:   []string{"tail", "-f", "/dev/null"}, // Keep the container running
-	},
-		&container.HostConfig{
-			Privileged: true, // Necessary to run the container in privileged mode
-		},
+	defer recordAction(OperationCreateContainer, containerName, start, &err)
+	resp, err := client.ContainerCreate(context.Background(), containerConfig,
+		hostConfig,
 		&network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{
 				networkName: {NetworkID: networkName}, // Connect the container to the specified network
@@ -44,18 +37,19 @@ func CreateNewContainer(image string, containerName string, networkName string,
 		nil,
 		containerName)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	end := time.Now()
-	p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Container %s created successfully", containerName)})
+	sendResult(p, OperationCreateContainer, end.Sub(start), fmt.Sprintf("Container %s created successfully", containerName))
 	return resp.ID, nil
 }
 
 // RemoveContainer removes a container given its ID and a pointer to a Docker client
 // It returns an error if the container removal fails
-func RemoveContainer(cli *client.Client, containerID string, p *tea.Program) error {
+func RemoveContainer(cli *client.Client, containerID string, p *tea.Program) (err error) {
 	// ContainerRemove options allow you to force stop a container before removing
 	start := time.Now()
+	defer recordAction(OperationRemoveContainer, containerID, start, &err)
 	removeOptions := container.RemoveOptions{
 		Force: true,
 	}
@@ -64,78 +58,90 @@ func RemoveContainer(cli *client.Client, containerID string, p *tea.Program) err
 		return err
 	}
 	end := time.Now()
-	p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Container %s removed successfully", containerID)})
+	sendResult(p, OperationRemoveContainer, end.Sub(start), fmt.Sprintf("Container %s removed successfully", containerID))
 	return nil
 }
 
-// CreateNetwork creates a new network given the network name and a pointer to a Docker client
+// CreateNetwork creates a new network given the network name, its subnet, its labels and a pointer to a Docker client
 // It returns the network Docker ID and an error if the network creation fails
-func CreateNetwork(name string, client *client.Client, p *tea.Program) (string, error) {
+func CreateNetwork(name string, subnet netip.Prefix, labels map[string]string, client *client.Client, p *tea.Program) (id string, err error) {
 	// Create the network
 	start := time.Now()
+	defer recordAction(OperationCreateNetwork, name, start, &err)
 	network, err := client.NetworkCreate(context.Background(), name, network.CreateOptions{
 		Driver: "bridge",
+		IPAM:   &network.IPAM{Config: []network.IPAMConfig{{Subnet: subnet.String()}}},
+		Labels: labels,
 	})
 	if err != nil {
 		return "", err
 	}
 	end := time.Now()
-	p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Network %s created successfully", name)})
+	sendResult(p, OperationCreateNetwork, end.Sub(start), fmt.Sprintf("Network %s created successfully", name))
 
 	return network.ID, nil
 }
 
 // RemoveNetwork removes a network given its ID and a pointer to a Docker client
 // It returns an error if the network removal fails
-func RemoveNetwork(cli *client.Client, networkID string, p *tea.Program) error {
+func RemoveNetwork(cli *client.Client, networkID string, p *tea.Program) (err error) {
 	// Remove the network
 	start := time.Now()
+	defer recordAction(OperationRemoveNetwork, networkID, start, &err)
 	if err := cli.NetworkRemove(context.Background(), networkID); err != nil {
 		return err
 	}
 	end := time.Now()
-	p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Network %s removed successfully", networkID)})
+	sendResult(p, OperationRemoveNetwork, end.Sub(start), fmt.Sprintf("Network %s removed successfully", networkID))
 	return nil
 }
 
-// DeleteAll removes all the containers and networks that contain the image_name and network_name
-// It returns an error if the removal fails
-func DeleteAll(cli *client.Client, config *config.Config, p *tea.Program) error {
-	// Get all the containers
+// meshResources returns the IDs of the containers and the networks of the virtual environment, the ones with its labels or named as its nodes and networks
+// It returns an error if the containers or the networks can't be listed
+func meshResources(cli *client.Client, config *config.Config) (containerIDs []string, networkIDs []string, err error) {
+	mesh := config.MeshName()
 	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
 		All: true,
 	})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	var containerIDs []string
-	// Filter those whose name contains the image_name
+	containerPattern := numberedNamePattern(containerNamePrefix(mesh))
 	for _, container := range containers {
 		for _, name := range container.Names {
-			if strings.Contains(name, *config.ImageName) {
+			if ownedBy(container.Labels, name, config.Project.Name, mesh, containerPattern) {
 				containerIDs = append(containerIDs, container.ID[:12])
+				break
 			}
 		}
 	}
-	// Remove all the selected containers
-	for _, containerID := range containerIDs {
-		err := RemoveContainer(cli, containerID, p)
-		if err != nil {
-			return err
-		}
-	}
-	// Get all the networks
 	networks, err := cli.NetworkList(context.Background(), network.ListOptions{})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	var networkIDs []string
-	// Filter those whose name contains the network_name
+	networkPattern := numberedNamePattern(config.NetworkPrefix())
 	for _, network := range networks {
-		if strings.Contains(network.Name, *config.NetworkName) {
+		if ownedBy(network.Labels, network.Name, config.Project.Name, mesh, networkPattern) {
 			networkIDs = append(networkIDs, network.ID)
 		}
 	}
+	return containerIDs, networkIDs, nil
+}
+
+// DeleteAll removes all the containers and networks of the virtual environment, the other environments are left untouched
+// It returns an error if the removal fails
+func DeleteAll(cli *client.Client, config *config.Config, p *tea.Program) error {
+	containerIDs, networkIDs, err := meshResources(cli, config)
+	if err != nil {
+		return err
+	}
+	// Remove all the selected containers
+	for _, containerID := range containerIDs {
+		err := RemoveContainer(cli, containerID, p)
+		if err != nil {
+			return err
+		}
+	}
 	// Remove all the selected networks
 	for _, networkID := range networkIDs {
 		err := RemoveNetwork(cli, networkID, p)
@@ -143,80 +149,131 @@ func DeleteAll(cli *client.Client, config *config.Config, p *tea.Program) error
 			return err
 		}
 	}
+	// Forget the faults of the removed containers
+	err = RemoveState(config.MeshName())
+	if err != nil {
+		return err
+	}
 	p.Quit()
 	return nil
 }
 
-// ContainerNameFromNodeNumber returns the container name given the node number and the image name
-func ContainerNameFromNodeNumber(nodeNumber int, imageName string) string {
-	return "cont_" + imageName + strconv.Itoa(nodeNumber)
+// containerNamePrefix returns the prefix shared by the names of the containers given the mesh name, the image name prefixed by the project name if one is set
+func containerNamePrefix(meshName string) string {
+	return "cont_" + meshName
+}
+
+// ContainerNameFromNodeNumber returns the container name given the node number and the mesh name
+func ContainerNameFromNodeNumber(nodeNumber int, meshName string) string {
+	return containerNamePrefix(meshName) + strconv.Itoa(nodeNumber)
+}
+
+// NodeHostConfig returns the host configuration of a node given a pointer to the config struct and the node number
+// It returns an error if the resource settings of the node are not valid
+func NodeHostConfig(config *config.Config, nodeNumber int) (*container.HostConfig, error) {
+	resources, err := DockerResources(config.ResourcesForNode(nodeNumber))
+	if err != nil {
+		return nil, fmt.Errorf("error in the resource settings of the node %d: %v", nodeNumber, err)
+	}
+	hostConfig := &container.HostConfig{
+		Resources: resources,
+	}
+	err = ApplySecuritySettings(hostConfig, config.Security)
+	if err != nil {
+		return nil, fmt.Errorf("error in the security settings: %v", err)
+	}
+	return hostConfig, nil
+}
+
+// NodeContainerConfig returns the container configuration of a node given a pointer to the config struct and the node number
+func NodeContainerConfig(config *config.Config, nodeNumber int) *container.Config {
+	labels := meshLabels(config)
+	labels[LabelNode] = strconv.Itoa(nodeNumber)
+	return &container.Config{
+		Labels:      labels,
+		Image:       config.ImageForNode(nodeNumber),
+		Cmd:         config.CommandForNode(nodeNumber),
+		Env:         config.EnvForNode(nodeNumber),
+		User:        config.Security.User,
+		Healthcheck: NodeHealthConfig(config.HealthCheckForNode(nodeNumber)),
+	}
 }
 
-// CreateContainers creates n containers given the image name, the number of containers, the network name and the number of networks and a pointer to a Docker client
-// It returns an error if the container creation fails
-func CreateContainers(cli *client.Client, imageName string, numContainers int, networkName string, numNetworks int, p *tea.Program) error {
+// CreateContainers creates the containers of every network and starts them following their dependencies given a pointer to a Docker client and a pointer to the config struct
+// It returns an error if the container creation or startup fails
+func CreateContainers(cli *client.Client, config *config.Config, p *tea.Program) error {
+	meshName := config.MeshName()
 	cont := 0
 	//for each network
-	for j := 0; j < numNetworks; j++ {
-		netName := networkName + strconv.Itoa(j)
+	for j := 0; j < *config.NumNetworks; j++ {
+		netName := config.NetworkPrefix() + strconv.Itoa(j)
 		//create the n containers
-		for i := 0; i < numContainers; i++ {
-			start := time.Now()
-			containerName := ContainerNameFromNodeNumber(cont, imageName)
-			contId, err := CreateNewContainer(imageName, containerName, netName, cli, p)
+		for i := 0; i < *config.NumContainers; i++ {
+			containerName := ContainerNameFromNodeNumber(cont, meshName)
+			hostConfig, err := NodeHostConfig(config, cont)
 			if err != nil {
-				return fmt.Errorf("error during the creation of the container: %v", err)
+				return err
 			}
-			err = cli.ContainerStart(context.Background(), contId, container.StartOptions{})
+			_, err = CreateNewContainer(containerName, netName, NodeContainerConfig(config, cont), hostConfig, cli, p)
 			if err != nil {
-				return fmt.Errorf("error during the startup of the container: %v", err)
+				return fmt.Errorf("error during the creation of the container: %v", err)
 			}
-			end := time.Now()
-			p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Container %s started successfully", containerName)})
 			cont++
 		}
 	}
-	return nil
+	return StartContainers(cli, config, p)
 }
 
 // StopContainer stops a container given its ID and a pointer to a Docker client
 // It returns an error if the container stopping fails
-func StopContainer(cli *client.Client, nodeNumber int, imageName string) error {
-	containerName := ContainerNameFromNodeNumber(nodeNumber, imageName)
+func StopContainer(cli *client.Client, nodeNumber int, meshName string) (err error) {
+	defer recordAction(OperationStopContainer, nodeTarget(nodeNumber), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
 	containerID, err := GetContainerID(cli, containerName)
 	if err != nil {
 		return fmt.Errorf("error during the retrieval of the container ID: %v", err)
 	}
+	start := time.Now()
 	err = cli.ContainerStop(context.Background(), containerID, container.StopOptions{})
 	// Stop the container
 	if err != nil {
 		return fmt.Errorf("error during the halting of the container %s:%v", containerID, err)
 	}
-	stoppedContainers = append(stoppedContainers, nodeNumber)
-	fmt.Printf("Container %s stopped successfully\n", containerID)
-	return nil
+	observeOperation(OperationStopContainer, time.Since(start))
+	logf("Container %s stopped successfully\n", containerID)
+	return recordFault(meshName, FaultRecord{Node: nodeNumber, Fault: FaultStop, Since: time.Now()})
 }
 
 // RestartContainer restarts a container given its ID and a pointer to a Docker client
 // It returns an error if the container restarting fails
-func RestartContainer(cli *client.Client, nodeNumber int, imageName string) error {
-	containerName := ContainerNameFromNodeNumber(nodeNumber, imageName)
+func RestartContainer(cli *client.Client, nodeNumber int, meshName string) (err error) {
+	defer recordAction(OperationStartContainer, nodeTarget(nodeNumber), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
 	containerID, err := GetContainerID(cli, containerName)
 	if err != nil {
 		return fmt.Errorf("error during the retrieval of the container ID: %v", err)
 	}
-	sort.Ints(stoppedContainers)
-	i := sort.SearchInts(stoppedContainers, nodeNumber)
-	if stoppedContainers != nil && stoppedContainers[i] == nodeNumber {
-		fmt.Printf("Container %d is stopped\n", stoppedContainers[i])
+	status, err := GetNodeStatus(cli, nodeNumber, meshName)
+	if err != nil {
+		return fmt.Errorf("error during the retrieval of the container status: %v", err)
+	}
+	if status.Stopped() {
+		logf("Container %d is %s\n", nodeNumber, status)
+		start := time.Now()
 		err := cli.ContainerStart(context.Background(), containerID, container.StartOptions{})
 		// Restart the container
 		if err != nil {
 			return fmt.Errorf("error during the restart of the container %s:%v", containerID, err)
 		}
-		fmt.Printf("Container %d restarted successfully\n", nodeNumber)
+		observeOperation(OperationStartContainer, time.Since(start))
+		logf("Container %d restarted successfully\n", nodeNumber)
+		if err := clearFault(meshName, nodeNumber); err != nil {
+			return err
+		}
+		// The interfaces are recreated without their delays
+		return ReapplyLatencies(cli, nodeNumber, meshName)
 	} else {
-		fmt.Printf("Container %d is not stopped\n", nodeNumber)
+		logf("Container %d is not stopped, it is %s\n", nodeNumber, status)
 	}
 	return nil
 }
@@ -239,18 +296,30 @@ func GetContainerID(cli *client.Client, containerName string) (string, error) {
 	return "", fmt.Errorf("container %s not found", containerName)
 }
 
-func GetStoppedContainers() []int {
-	return stoppedContainers
-}
-
-// CreateNetworks creates n networks given the network name and the number of networks and a pointer to a Docker client
-// It returns an error if the network creation fails
-func CreateNetworks(cli *client.Client, networkName string, numNetworks int, p *tea.Program) error {
-	for i := 0; i < numNetworks; i++ {
-		netName := networkName + strconv.Itoa(i)
-		_, err := CreateNetwork(netName, cli, p)
-		if err != nil {
-			return fmt.Errorf("error during the creation of the networks: %v", err)
+// CreateNetworks creates the networks of the virtual environment given a pointer to a Docker client and a pointer to the config struct
+// Every network gets a subnet of the pool not used by the other networks of the host
+// It returns an error if the network creation fails or the pool is exhausted
+func CreateNetworks(cli *client.Client, config *config.Config, p *tea.Program) error {
+	allocator, err := newSubnetAllocator(cli, config.Project)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *config.NumNetworks; i++ {
+		labels := meshLabels(config)
+		labels[LabelNetwork] = strconv.Itoa(i)
+		for {
+			subnet, err := allocator.allocate()
+			if err != nil {
+				return err
+			}
+			_, err = CreateNetwork(networkName(config, i), subnet, labels, cli, p)
+			if err == nil {
+				break
+			}
+			// Another environment may have taken the subnet since the networks were listed
+			if !isSubnetOverlap(err) {
+				return fmt.Errorf("error during the creation of the networks: %v", err)
+			}
 		}
 	}
 	fmt.Println("All networks created successfully")
@@ -290,15 +359,17 @@ func BuildDockerImage(client *client.Client, config *config.Config) error {
 	return nil
 }
 
-// ConnectNetworks connects the containers of the first network to the second network given the network IDs, the container name, the image name, the number of containers, the number of networks, the number of links adn a pointer to a Docker client
+// ConnectNetworks connects the containers of the first network to the second network given the network IDs, the container name, the mesh name, the number of containers, the number of networks, the number of links adn a pointer to a Docker client
 // It returns an error if the connection fails
-func ConnectNetworks(cli *client.Client, network1 int, network2 int, networkName string, imageName string, numContainers int, numNetworks int, numLinks int) error {
+func ConnectNetworks(cli *client.Client, network1 int, network2 int, networkName string, meshName string, numContainers int, numNetworks int, numLinks int) error {
 	netName2 := networkName + strconv.Itoa(network2)
 	for i := 0; i < numLinks; i++ {
 		//select container on the first network
-		container1 := "cont_" + imageName + strconv.Itoa(network1*numContainers+i)
+		container1 := "cont_" + meshName + strconv.Itoa(network1*numContainers+i)
 		//connect the container to the second network// Function to connect 2 networks by adding a node of the first network to the second network
+		start := time.Now()
 		err := cli.NetworkConnect(context.Background(), netName2, container1, nil)
+		recordActionDetails(OperationConnect, linkTarget(Link{Node: network1*numContainers + i, From: network1, To: network2}), "", start, err)
 		if err != nil {
 			return fmt.Errorf("error during the connection of the container to the network: %v", err)
 		}
@@ -310,8 +381,15 @@ func ConnectNetworks(cli *client.Client, network1 int, network2 int, networkName
 // CreateLinks creates the links between the networks given the pointer to a Docker client and a pointer to the config struct
 // It returns an error if the linking fails
 func CreateLinks(cli *client.Client, config *config.Config, p *tea.Program) error {
-	if config.NetMatrix == nil {
-		config.NetMatrix = *CreateMatrix(*config.NumNetworks)
+	if len(config.NetMatrix) != *config.NumNetworks {
+		return fmt.Errorf("the adjacency matrix must have %d rows, edit it before creating the links", *config.NumNetworks)
+	}
+	// Save the matrix for the commands run from another process
+	err := UpdateState(config.MeshName(), func(state *MeshState) {
+		state.NetMatrix = config.NetMatrix
+	})
+	if err != nil {
+		return err
 	}
 	// Create the links
 	for i := 0; i < *config.NumNetworks; i++ {
@@ -319,12 +397,12 @@ func CreateLinks(cli *client.Client, config *config.Config, p *tea.Program) erro
 			if (config.NetMatrix)[i][j] && i != j { // If there is a link between the networks and they are different
 				start := time.Now()
 				// Connect the containers to the network
-				err := ConnectNetworks(cli, i, j, *config.NetworkName, *config.ImageName, *config.NumContainers, *config.NumNetworks, *config.NumLinks)
+				err := ConnectNetworks(cli, i, j, config.NetworkPrefix(), config.MeshName(), *config.NumContainers, *config.NumNetworks, *config.NumLinks)
 				if err != nil {
 					return fmt.Errorf("error during the linking of 2 networks: %v", err)
 				}
 				end := time.Now()
-				p.Send(resultMsg{end.Sub(start), fmt.Sprintf("Network %d linked to network %d", i, j)})
+				sendResult(p, OperationLinkNetworks, end.Sub(start), fmt.Sprintf("Network %d linked to network %d", i, j))
 			}
 		}
 	}
@@ -332,44 +410,6 @@ func CreateLinks(cli *client.Client, config *config.Config, p *tea.Program) erro
 	return nil
 }
 
-// CreateMatrix creates the adjacency matrix given the number of networks
-// It returns a pointer to the adjacency matrix
-func CreateMatrix(numNetworks int) *[][]bool {
-	// Create the matrix of links
-	matrix := make([][]bool, numNetworks)
-	fmt.Println("Please replay at the following questions for creating the adjacency matrix:")
-	//read the matrix
-	reader := bufio.NewReader(os.Stdin)
-	readMatrix := true
-	for readMatrix {
-		for i := 0; i < numNetworks; i++ {
-			matrix[i] = make([]bool, numNetworks)
-			for j := 0; j < numNetworks; j++ {
-				if i == j {
-					continue
-				}
-				fmt.Printf("Do you want a link between network %d and network %d (Y/N): ", i, j)
-				text, _ := reader.ReadString('\n')
-				text = strings.Replace(text, "\n", "", -1)
-				if strings.ToUpper(text) == "Y" {
-					matrix[i][j] = true
-				} else {
-					matrix[i][j] = false
-				}
-			}
-		}
-		// Print the matrix
-		PrintMatrix(&matrix, numNetworks)
-		fmt.Print("Is the adjacency matrix correct?(Y/N): ")
-		text, _ := reader.ReadString('\n')
-		text = strings.Replace(text, "\n", "", -1)
-		if strings.ToUpper(text) == "Y" {
-			readMatrix = false
-		}
-	}
-	return &matrix
-}
-
 // PrintMatrix prints the adjacency matrix given a pointer to the matrix and the number of networks
 func PrintMatrix(matrix *[][]bool, numNetwork int) {
 	fmt.Println("The adjacency matrix is:")
@@ -399,12 +439,12 @@ func PrintMatrix(matrix *[][]bool, numNetwork int) {
 // It returns an error if the creation fails
 func CreateVirtualEnviroment(cli *client.Client, config *config.Config, p *tea.Program) error {
 	// Create the networks
-	err := CreateNetworks(cli, *config.NetworkName, *config.NumNetworks, p)
+	err := CreateNetworks(cli, config, p)
 	if err != nil {
 		return fmt.Errorf("error during the creation of the networks: %v", err)
 	}
 	// Create the containers
-	err = CreateContainers(cli, *config.ImageName, *config.NumContainers, *config.NetworkName, *config.NumNetworks, p)
+	err = CreateContainers(cli, config, p)
 	if err != nil {
 		return fmt.Errorf("error during the creation of the containers: %v", err)
 	}
@@ -415,18 +455,75 @@ func CreateVirtualEnviroment(cli *client.Client, config *config.Config, p *tea.P
 			return fmt.Errorf("error during the creation of the links: %v", err)
 		}
 	}
+	// Wait for the containers to be ready
+	if *config.ReadyTimeout > 0 {
+		err = WaitReady(cli, config, *config.ReadyTimeout, p)
+		if err != nil {
+			return fmt.Errorf("error while waiting for the containers: %v", err)
+		}
+	}
 	p.Quit()
 
 	return nil
 }
 
-func GetGraphEncoding(config *config.Config) gin.H {
+// GetGraphEncoding returns the encoding of the virtual environment graph with the live status of the nodes
+// It returns an error if the status of the nodes can't be retrieved
+func GetGraphEncoding(cli *client.Client, config *config.Config) (gin.H, error) {
+	statuses, err := GetNodesStatus(cli, config)
+	if err != nil {
+		return nil, err
+	}
+	stopped := []int{}
+	for _, status := range statuses {
+		if status.Stopped() {
+			stopped = append(stopped, status.Node)
+		}
+	}
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return nil, err
+	}
 	graph := gin.H{
 		"NumNetworks":       *config.NumNetworks,
 		"NumContainers":     *config.NumContainers,
 		"NumLinks":          *config.NumLinks,
-		"StoppedContainers": stoppedContainers,
+		"StoppedContainers": stopped,
+		"NodeStatus":        statuses,
 		"NetMatrix":         config.NetMatrix,
+		"Links":             Links(config),
+		"LinkFaults":        state.LinkFaults,
+	}
+	return graph, nil
+}
+
+// PullNodeImages pulls the images of the nodes that are not available locally, e.g. the ones set by the node groups or imported from a compose file
+// It returns an error if an image can't be pulled
+func PullNodeImages(cli *client.Client, config *config.Config) error {
+	pulled := map[string]bool{}
+	for node := 0; node < config.TotalNodes(); node++ {
+		ref := config.ImageForNode(node)
+		if pulled[ref] {
+			continue
+		}
+		pulled[ref] = true
+		if _, _, err := cli.ImageInspectWithRaw(context.Background(), ref); err == nil {
+			continue
+		} else if !client.IsErrNotFound(err) {
+			return fmt.Errorf("error during the inspection of the image %s: %v", ref, err)
+		}
+		out, err := cli.ImagePull(context.Background(), ref, image.PullOptions{})
+		if err != nil {
+			return fmt.Errorf("error pulling the image %s: %v", ref, err)
+		}
+		// Shows the pull output
+		termFd, isTerm := term.GetFdInfo(os.Stderr)
+		err = jsonmessage.DisplayJSONMessagesStream(out, os.Stderr, termFd, isTerm, nil)
+		out.Close()
+		if err != nil {
+			return fmt.Errorf("error pulling the image %s: %v", ref, err)
+		}
+		fmt.Printf("Image %s pulled successfully\n", ref)
 	}
-	return graph
+	return nil
 }
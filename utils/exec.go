@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ExecResult is the outcome of a command executed in a node
+type ExecResult struct {
+	Node     int    `json:"Node"`
+	Stdout   string `json:"Stdout"`
+	Stderr   string `json:"Stderr"`
+	ExitCode int    `json:"ExitCode"`
+	Error    string `json:"Error,omitempty"` // Set if the command couldn't be executed
+}
+
+// ExecInContainer runs a command in a node and waits for it to finish, collecting its output and exit code
+// It returns an error if the command can't be executed
+func ExecInContainer(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, cmd []string) (result ExecResult, err error) {
+	start := time.Now()
+	defer func() {
+		recordActionDetails(OperationExec, nodeTarget(nodeNumber), fmt.Sprintf("%s, exit %d", strings.Join(cmd, " "), result.ExitCode), start, err)
+	}()
+	result = ExecResult{Node: nodeNumber}
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	// The processes of the command are marked to find them if they outlive the context
+	marker := fmt.Sprintf("%s=%d-%d", execMarker, nodeNumber, time.Now().UnixNano())
+	exec, err := cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{marker},
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return result, fmt.Errorf("error during the creation of the exec in the container %s: %v", containerName, err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return result, fmt.Errorf("error during the attach to the exec in the container %s: %v", containerName, err)
+	}
+	defer resp.Close()
+	// The output is multiplexed, split it in stdout and stderr
+	// It is read in the background, closing the connection doesn't stop the command so it is killed if it outlives the context
+	var stdout, stderr bytes.Buffer
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+		copied <- err
+	}()
+	select {
+	case <-ctx.Done():
+		resp.Close()
+		<-copied
+		// The output read so far is kept
+		result.Stdout = stdout.String()
+		result.Stderr = stderr.String()
+		if err := killExec(cli, containerName, marker); err != nil {
+			return result, fmt.Errorf("%v, %v", ctx.Err(), err)
+		}
+		return result, ctx.Err()
+	case err := <-copied:
+		if err != nil {
+			return result, fmt.Errorf("error reading the output of the exec in the container %s: %v", containerName, err)
+		}
+	}
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return result, fmt.Errorf("error during the inspection of the exec in the container %s: %v", containerName, err)
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.ExitCode = inspect.ExitCode
+	return result, nil
+}
+
+// execMarker is the environment variable that marks the processes of a command run by ExecInContainer
+const execMarker = "CONTAINMESH_EXEC"
+
+// killExec kills the processes of a command that outlived its context, found by the marker in their environment
+// The Pid of the exec is the one of the host, so the processes are looked for in the node
+// It returns an error if the command that kills them can't be executed
+func killExec(cli *client.Client, containerName string, marker string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	script := fmt.Sprintf(`for p in /proc/[0-9]*; do tr '\0' '\n' < $p/environ 2>/dev/null | grep -qx '%s' && kill -KILL ${p#/proc/}; done`, marker)
+	exec, err := cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{Cmd: ShellCommand(script)})
+	if err != nil {
+		return fmt.Errorf("error during the creation of the exec killing the command in the container %s: %v", containerName, err)
+	}
+	if err := cli.ContainerExecStart(ctx, exec.ID, container.ExecStartOptions{Detach: true}); err != nil {
+		return fmt.Errorf("error during the start of the exec killing the command in the container %s: %v", containerName, err)
+	}
+	return nil
+}
+
+// ExecOnNodes runs a command concurrently on the nodes and collects the result of every node, sorted by node
+// The nodes where the command can't be executed have the Error field set
+func ExecOnNodes(ctx context.Context, cli *client.Client, meshName string, nodes []int, cmd []string) []ExecResult {
+	results := make([]ExecResult, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node int) {
+			defer wg.Done()
+			result, err := ExecInContainer(ctx, cli, node, meshName, cmd)
+			if err != nil {
+				result.Error = err.Error()
+				result.ExitCode = -1
+			}
+			results[i] = result
+		}(i, node)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Node < results[j].Node })
+	return results
+}
+
+// ExecGroup is a set of nodes that produced the same result
+type ExecGroup struct {
+	Nodes  []int      `json:"Nodes"`
+	Result ExecResult `json:"Result"` // Result shared by the nodes, its Node field is not meaningful
+}
+
+// GroupExecResults groups the nodes with the same output, exit code and error, the groups are sorted by their first node
+func GroupExecResults(results []ExecResult) []ExecGroup {
+	var groups []ExecGroup
+	index := map[ExecResult]int{}
+	for _, result := range results {
+		key := result
+		key.Node = 0
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ExecGroup{Result: key})
+		}
+		groups[i].Nodes = append(groups[i].Nodes, result.Node)
+	}
+	return groups
+}
+
+// PrintExecResults prints the results grouped by identical output
+func PrintExecResults(results []ExecResult) {
+	WriteExecResults(os.Stdout, results)
+}
+
+// WriteExecResults writes the results grouped by identical output
+func WriteExecResults(w io.Writer, results []ExecResult) {
+	for _, group := range GroupExecResults(results) {
+		outcome := passStyle.Render(fmt.Sprintf("exit %d", group.Result.ExitCode))
+		if group.Result.ExitCode != 0 {
+			outcome = failStyle.Render(fmt.Sprintf("exit %d", group.Result.ExitCode))
+		}
+		fmt.Fprintf(w, "nodes %s (%s):\n", config.FormatNodeSelection(group.Nodes), outcome)
+		if group.Result.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", group.Result.Error)
+		}
+		for _, line := range strings.Split(strings.TrimRight(group.Result.Stdout, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+		}
+		for _, line := range strings.Split(strings.TrimRight(group.Result.Stderr, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "  %s %s\n", failStyle.Render("!"), line)
+			}
+		}
+	}
+}
+
+// FailedNodes returns the nodes where the command failed or couldn't be executed
+func FailedNodes(results []ExecResult) []int {
+	var nodes []int
+	for _, result := range results {
+		if result.ExitCode != 0 || result.Error != "" {
+			nodes = append(nodes, result.Node)
+		}
+	}
+	return nodes
+}
+
+// ShellCommand returns the command that runs the given command line with the shell of the nodes
+func ShellCommand(commandLine string) []string {
+	return []string{"/bin/sh", "-c", commandLine}
+}
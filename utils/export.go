@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Formats of the exported topology
+const (
+	ExportDOT     = "dot"
+	ExportMermaid = "mermaid"
+	ExportJSON    = "json"
+	ExportCompose = "compose" // docker-compose file of the expanded configuration
+)
+
+// ExportFormats are the formats accepted by the export command
+var ExportFormats = []string{ExportDOT, ExportMermaid, ExportJSON, ExportCompose}
+
+// GraphNetwork is a network of the exported topology
+type GraphNetwork struct {
+	Network int    `json:"Network"`
+	Name    string `json:"Name"` // Docker network name
+}
+
+// GraphNode is a node of the exported topology
+type GraphNode struct {
+	Node    int         `json:"Node"`
+	Name    string      `json:"Name"` // Container name
+	Network int         `json:"Network"`
+	Bridge  bool        `json:"Bridge"`           // The node is also connected to other networks
+	Status  *NodeStatus `json:"Status,omitempty"` // Live status, only when requested
+}
+
+// GraphLink is a link of the exported topology, a bridge node connected to another network
+type GraphLink struct {
+	Link
+	Down    bool          `json:"Down"`              // Cut by a fault
+	Fault   string        `json:"Fault,omitempty"`   // Fault that cut the link
+	Latency time.Duration `json:"Latency,omitempty"` // Delay added by a latency fault of the bridge node or by the topology file
+}
+
+// TopologyGraph is the topology of the virtual environment as exported by the export command
+type TopologyGraph struct {
+	Networks []GraphNetwork `json:"Networks"`
+	Nodes    []GraphNode    `json:"Nodes"`
+	Links    []GraphLink    `json:"Links"`
+	Live     bool           `json:"Live"` // The nodes have their live status
+}
+
+// BuildTopologyGraph builds the topology of the virtual environment given a pointer to a Docker client and a pointer to the config struct, from the links of the adjacency matrix and the faults of the state
+// The live status of the nodes is read from the daemon only if requested
+// It returns an error if the state or the status of the nodes can't be read
+func BuildTopologyGraph(cli *client.Client, config *config.Config, live bool) (*TopologyGraph, error) {
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return nil, err
+	}
+	var statuses []NodeStatus
+	if live {
+		statuses, err = GetNodesStatus(cli, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	graph := &TopologyGraph{Live: live}
+	for network := 0; network < *config.NumNetworks; network++ {
+		graph.Networks = append(graph.Networks, GraphNetwork{Network: network, Name: networkName(config, network)})
+	}
+	links := Links(config)
+	for node := 0; node < config.TotalNodes(); node++ {
+		graphNode := GraphNode{
+			Node:    node,
+			Name:    ContainerNameFromNodeNumber(node, config.MeshName()),
+			Network: node / *config.NumContainers,
+			Bridge:  slices.ContainsFunc(links, func(link Link) bool { return link.Node == node }),
+		}
+		if live {
+			graphNode.Status = &statuses[node]
+		}
+		graph.Nodes = append(graph.Nodes, graphNode)
+	}
+	for _, link := range links {
+		graphLink := GraphLink{Link: link}
+		for _, fault := range state.LinkFaults {
+			if slices.Contains(fault.Links, link) {
+				graphLink.Down, graphLink.Fault = true, fault.Fault
+			}
+		}
+		// Without the live status the latency of the state is trusted
+		fault, ok := state.Faults[link.Node]
+		if live {
+			ok = statuses[link.Node].Fault != nil
+			if ok {
+				fault = *statuses[link.Node].Fault
+			}
+		}
+		if ok && fault.Fault == FaultLatency {
+			graphLink.Latency = fault.Latency
+		}
+		// The latency of a cut link is set again when it is healed
+		for _, latency := range state.Latencies {
+			if latency.Link == link && !graphLink.Down {
+				graphLink.Latency += latency.Latency
+			}
+		}
+		graph.Links = append(graph.Links, graphLink)
+	}
+	return graph, nil
+}
+
+// statusColors are the fill colors of the nodes by state, the same as the web UI
+var statusColors = map[string]string{
+	NodeRunning: "#2ecc71", NodePaused: "#f1c40f", NodeRestarting: "#3498db", NodeCreated: "#95a5a6",
+	NodeExited: "#e74c3c", NodeDead: "#c0392b", NodeOOMKilled: "#9b59b6", NodeMissing: "#555770",
+}
+
+// linkLabel returns the label of a link with its properties, empty for a plain link
+func linkLabel(link GraphLink) string {
+	var properties []string
+	if link.Down {
+		properties = append(properties, link.Fault)
+	}
+	if link.Latency != 0 {
+		properties = append(properties, "+"+link.Latency.String())
+	}
+	return strings.Join(properties, " ")
+}
+
+// WriteDOT writes the topology in the Graphviz DOT format, every network is a cluster with a hub the links point to
+func WriteDOT(w io.Writer, graph *TopologyGraph) error {
+	var b strings.Builder
+	b.WriteString("graph containmesh {\n  node [fontname=\"Helvetica\"];\n  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, network := range graph.Networks {
+		fmt.Fprintf(&b, "  subgraph cluster_net%d {\n    label=%q;\n    color=\"#7c6cf0\";\n", network.Network, network.Name)
+		fmt.Fprintf(&b, "    net%d [label=\"net %d\", shape=box, style=rounded];\n", network.Network, network.Network)
+		for _, node := range graph.Nodes {
+			if node.Network != network.Network {
+				continue
+			}
+			attributes := []string{fmt.Sprintf("label=\"%d\"", node.Node), fmt.Sprintf("tooltip=%q", node.Name)}
+			if node.Bridge {
+				attributes = append(attributes, "shape=doublecircle")
+			} else {
+				attributes = append(attributes, "shape=circle")
+			}
+			if node.Status != nil {
+				attributes = append(attributes, "style=filled", fmt.Sprintf("fillcolor=%q", statusColors[node.Status.State]), fmt.Sprintf("xlabel=%q", node.Status.String()))
+			}
+			fmt.Fprintf(&b, "    node%d [%s];\n", node.Node, strings.Join(attributes, ", "))
+			fmt.Fprintf(&b, "    node%d -- net%d [color=\"#8a8ba0\"];\n", node.Node, network.Network)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, link := range graph.Links {
+		attributes := []string{"penwidth=2"}
+		if link.Down {
+			attributes = append(attributes, "style=dashed", "color=\"#e74c3c\"")
+		} else {
+			attributes = append(attributes, "color=\"#5dade2\"")
+		}
+		if label := linkLabel(link); label != "" {
+			attributes = append(attributes, fmt.Sprintf("label=%q", label))
+		}
+		fmt.Fprintf(&b, "  node%d -- net%d [%s];\n", link.Node, link.To, strings.Join(attributes, ", "))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the topology as a Mermaid flowchart, every network is a subgraph with a hub the links point to
+func WriteMermaid(w io.Writer, graph *TopologyGraph) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, network := range graph.Networks {
+		fmt.Fprintf(&b, "  subgraph cluster_net%d[\"%s\"]\n    net%d{{\"net %d\"}}\n", network.Network, network.Name, network.Network, network.Network)
+		for _, node := range graph.Nodes {
+			if node.Network != network.Network {
+				continue
+			}
+			label := fmt.Sprint(node.Node)
+			if node.Status != nil {
+				label += "<br/>" + node.Status.State
+			}
+			if node.Bridge {
+				fmt.Fprintf(&b, "    node%d(((\"%s\")))\n", node.Node, label)
+			} else {
+				fmt.Fprintf(&b, "    node%d((\"%s\"))\n", node.Node, label)
+			}
+			fmt.Fprintf(&b, "    node%d --- net%d\n", node.Node, network.Network)
+		}
+		b.WriteString("  end\n")
+	}
+	// The style of an edge is set by its index, counted from the first edge of the chart
+	edge := len(graph.Nodes)
+	var down []string
+	for _, link := range graph.Links {
+		arrow := "==="
+		if link.Down {
+			arrow = "-.-"
+			down = append(down, fmt.Sprint(edge))
+		}
+		if label := linkLabel(link); label != "" {
+			fmt.Fprintf(&b, "  node%d %s|\"%s\"| net%d\n", link.Node, arrow, label, link.To)
+		} else {
+			fmt.Fprintf(&b, "  node%d %s net%d\n", link.Node, arrow, link.To)
+		}
+		edge++
+	}
+	if len(down) > 0 {
+		fmt.Fprintf(&b, "  linkStyle %s stroke:#e74c3c\n", strings.Join(down, ","))
+	}
+	if graph.Live {
+		states := make([]string, 0, len(statusColors))
+		for state := range statusColors {
+			states = append(states, state)
+		}
+		slices.Sort(states)
+		for _, state := range states {
+			var nodes []string
+			for _, node := range graph.Nodes {
+				if node.Status != nil && node.Status.State == state {
+					nodes = append(nodes, fmt.Sprintf("node%d", node.Node))
+				}
+			}
+			if len(nodes) > 0 {
+				class := strings.ReplaceAll(state, "-", "_")
+				fmt.Fprintf(&b, "  classDef %s fill:%s\n  class %s %s\n", class, statusColors[state], strings.Join(nodes, ","), class)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteNodeLinkJSON writes the topology in the node-link JSON format read by d3 and networkx, the networks and the nodes are vertices and the memberships and the links are edges
+func WriteNodeLinkJSON(w io.Writer, graph *TopologyGraph) error {
+	type vertex struct {
+		ID      string      `json:"id"`
+		Kind    string      `json:"kind"` // network or node
+		Name    string      `json:"name"`
+		Network int         `json:"network"`
+		Node    *int        `json:"node,omitempty"`
+		Bridge  bool        `json:"bridge,omitempty"`
+		Status  *NodeStatus `json:"status,omitempty"`
+	}
+	type edge struct {
+		Source  string        `json:"source"`
+		Target  string        `json:"target"`
+		Kind    string        `json:"kind"` // member or link
+		Down    bool          `json:"down,omitempty"`
+		Fault   string        `json:"fault,omitempty"`
+		Latency time.Duration `json:"latency,omitempty"`
+	}
+	data := struct {
+		Directed   bool           `json:"directed"`
+		Multigraph bool           `json:"multigraph"`
+		Graph      map[string]any `json:"graph"`
+		Nodes      []vertex       `json:"nodes"`
+		Links      []edge         `json:"links"`
+	}{Graph: map[string]any{"name": "containmesh", "live": graph.Live}, Nodes: []vertex{}, Links: []edge{}}
+	for _, network := range graph.Networks {
+		data.Nodes = append(data.Nodes, vertex{ID: fmt.Sprintf("net%d", network.Network), Kind: "network", Name: network.Name, Network: network.Network})
+	}
+	for _, node := range graph.Nodes {
+		data.Nodes = append(data.Nodes, vertex{ID: fmt.Sprintf("node%d", node.Node), Kind: "node", Name: node.Name, Network: node.Network,
+			Node: &node.Node, Bridge: node.Bridge, Status: node.Status})
+		data.Links = append(data.Links, edge{Source: fmt.Sprintf("node%d", node.Node), Target: fmt.Sprintf("net%d", node.Network), Kind: "member"})
+	}
+	for _, link := range graph.Links {
+		data.Links = append(data.Links, edge{Source: fmt.Sprintf("node%d", link.Node), Target: fmt.Sprintf("net%d", link.To), Kind: "link",
+			Down: link.Down, Fault: link.Fault, Latency: link.Latency})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// ExportFormatFromPath returns the format matching the extension of a file and false if the extension is not known
+func ExportFormatFromPath(path string) (string, bool) {
+	base := strings.ToLower(filepath.Base(path))
+	if strings.Contains(base, "compose") && (strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")) {
+		return ExportCompose, true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		return ExportDOT, true
+	case ".mmd", ".mermaid":
+		return ExportMermaid, true
+	case ".json":
+		return ExportJSON, true
+	}
+	return "", false
+}
+
+// ExportTopology writes the topology in the given format
+// It returns an error if the format is not known or the topology can't be written
+func ExportTopology(w io.Writer, graph *TopologyGraph, format string) error {
+	switch format {
+	case ExportDOT:
+		return WriteDOT(w, graph)
+	case ExportMermaid:
+		return WriteMermaid(w, graph)
+	case ExportJSON:
+		return WriteNodeLinkJSON(w, graph)
+	}
+	return fmt.Errorf("unknown export format %q, it must be one of %v", format, ExportFormats)
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// PauseContainer freezes all the processes of a container with the cgroup freezer given the node number and the mesh name
+// It returns an error if the container pausing fails
+func PauseContainer(cli *client.Client, nodeNumber int, meshName string) (err error) {
+	defer recordAction(OperationPause, nodeTarget(nodeNumber), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	err = cli.ContainerPause(context.Background(), containerName)
+	if err != nil {
+		return fmt.Errorf("error during the pausing of the container %s: %v", containerName, err)
+	}
+	logf("Container %d paused successfully\n", nodeNumber)
+	return recordFault(meshName, FaultRecord{Node: nodeNumber, Fault: FaultPause, Since: time.Now()})
+}
+
+// UnpauseContainer resumes the processes of a paused container given the node number and the mesh name
+// It returns an error if the container unpausing fails
+func UnpauseContainer(cli *client.Client, nodeNumber int, meshName string) (err error) {
+	defer recordAction(OperationUnpause, nodeTarget(nodeNumber), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	err = cli.ContainerUnpause(context.Background(), containerName)
+	if err != nil {
+		return fmt.Errorf("error during the unpausing of the container %s: %v", containerName, err)
+	}
+	logf("Container %d unpaused successfully\n", nodeNumber)
+	return clearFault(meshName, nodeNumber)
+}
+
+// normalizeSignal returns the signal name in upper case with the SIG prefix, numeric signals are left untouched
+func normalizeSignal(signal string) string {
+	signal = strings.ToUpper(strings.TrimSpace(signal))
+	if signal == "" {
+		return "SIGKILL"
+	}
+	if signal[0] >= '0' && signal[0] <= '9' || strings.HasPrefix(signal, "SIG") {
+		return signal
+	}
+	return "SIG" + signal
+}
+
+// KillContainer sends a signal to the main process of a container given the node number, the mesh name and the signal (e.g. SIGKILL, SIGSTOP)
+// SIGCONT resumes a container stopped with SIGSTOP and clears its fault
+// It returns an error if the signal can't be sent
+func KillContainer(cli *client.Client, nodeNumber int, meshName string, signal string) (err error) {
+	signal = normalizeSignal(signal)
+	start := time.Now()
+	defer func() { recordActionDetails(OperationKill, nodeTarget(nodeNumber), signal, start, err) }()
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	err = cli.ContainerKill(context.Background(), containerName, signal)
+	if err != nil {
+		return fmt.Errorf("error during the killing of the container %s: %v", containerName, err)
+	}
+	logf("Signal %s sent to container %d successfully\n", signal, nodeNumber)
+	if signal == "SIGCONT" {
+		return clearFault(meshName, nodeNumber)
+	}
+	return recordFault(meshName, FaultRecord{Node: nodeNumber, Fault: FaultKill, Signal: signal, Since: time.Now()})
+}
+
+// RestartContainerWithDelay stops a container and starts it again after the given delay
+// It blocks until the container is started again
+// It returns an error if the stopping or the starting of the container fails
+func RestartContainerWithDelay(cli *client.Client, nodeNumber int, meshName string, delay time.Duration) (err error) {
+	start := time.Now()
+	defer func() {
+		recordActionDetails(OperationDelayedRestart, nodeTarget(nodeNumber), delay.String(), start, err)
+	}()
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	err = cli.ContainerStop(context.Background(), containerName, container.StopOptions{})
+	if err != nil {
+		return fmt.Errorf("error during the halting of the container %s: %v", containerName, err)
+	}
+	err = recordFault(meshName, FaultRecord{Node: nodeNumber, Fault: FaultRestart, Delay: delay, Since: time.Now()})
+	if err != nil {
+		return err
+	}
+	logf("Container %d stopped, it will be restarted in %v\n", nodeNumber, delay)
+	time.Sleep(delay)
+	err = cli.ContainerStart(context.Background(), containerName, container.StartOptions{})
+	if err != nil {
+		return fmt.Errorf("error during the restart of the container %s: %v", containerName, err)
+	}
+	logf("Container %d restarted successfully\n", nodeNumber)
+	if err := clearFault(meshName, nodeNumber); err != nil {
+		return err
+	}
+	return ReapplyLatencies(cli, nodeNumber, meshName)
+}
+
+// activeFault returns the fault of a node that is still consistent with its live status
+// Faults made stale by changes done outside ContainMesh (e.g. docker start) are ignored
+func activeFault(state *MeshState, status NodeStatus) *FaultRecord {
+	fault, ok := state.Faults[status.Node]
+	if !ok {
+		return nil
+	}
+	switch fault.Fault {
+	case FaultPause:
+		ok = status.State == NodePaused
+	case FaultStop, FaultRestart, FaultCrash, FaultOOM:
+		ok = status.Stopped()
+	case FaultKill:
+		ok = status.Stopped() || fault.Signal == "SIGSTOP" || fault.Signal == "19"
+	case FaultLatency:
+		ok = status.State == NodeRunning || status.State == NodePaused
+	}
+	if !ok {
+		return nil
+	}
+	return &fault
+}
+
+// HealAll removes every fault recorded in the state: the stopped and killed containers are started again, the paused and frozen ones are resumed, the latencies are removed and the links reconnected
+// It returns an error if a fault can't be healed
+func HealAll(cli *client.Client, config *config.Config) error {
+	statuses, err := GetNodesStatus(cli, config)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Fault == nil {
+			continue
+		}
+		switch {
+		case status.State == NodePaused:
+			err = UnpauseContainer(cli, status.Node, config.MeshName())
+		case status.Stopped():
+			err = RestartContainer(cli, status.Node, config.MeshName())
+		case status.Fault.Fault == FaultKill:
+			err = KillContainer(cli, status.Node, config.MeshName(), "SIGCONT")
+		case status.Fault.Fault == FaultLatency:
+			err = SetLatency(cli, status.Node, config.MeshName(), 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return HealAllLinks(cli, config)
+}
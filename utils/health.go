@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+const readyPollInterval = 500 * time.Millisecond
+
+var (
+	healthyStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	startingStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	unhealthyStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// healthMsg reports the health status of a node to the loading spinner
+type healthMsg struct {
+	node   int
+	status string
+}
+
+// NodeHealthConfig converts the health check settings of a node into the Docker health check
+// It returns nil if the node has no health check command
+func NodeHealthConfig(settings config.HealthCheckSettings) *container.HealthConfig {
+	if settings.Command == "" {
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", settings.Command},
+		Interval:    settings.Interval,
+		Timeout:     settings.Timeout,
+		Retries:     settings.Retries,
+		StartPeriod: settings.StartPeriod,
+	}
+}
+
+// NodeHealth returns the health status of a node, its state if the node has no health check
+// It returns an error if the container can't be inspected
+func NodeHealth(cli *client.Client, nodeNumber int, meshName string) (string, error) {
+	status, err := GetNodeStatus(cli, nodeNumber, meshName)
+	if err != nil {
+		return "", err
+	}
+	if status.Health != "" && status.State == NodeRunning {
+		return status.Health, nil
+	}
+	return status.State, nil
+}
+
+// isReady reports whether a health status means that the node is ready
+func isReady(status string) bool {
+	return status == types.Healthy || status == NodeRunning
+}
+
+// isFailed reports whether a health status means that the node stopped and will never be ready by itself
+func isFailed(status string) bool {
+	return status == NodeExited || status == NodeOOMKilled || status == NodeDead || status == NodeMissing
+}
+
+// WaitReady waits until every node is healthy (or running if it has no health check) given a pointer to a Docker client, a pointer to the config struct and the timeout
+// It returns an error listing the nodes that stopped as soon as one does, or the nodes that are not ready when the timeout elapses
+func WaitReady(cli *client.Client, config *config.Config, timeout time.Duration, p *tea.Program) error {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	health := map[int]string{}
+	for {
+		var notReady, failed []int
+		for node := 0; node < config.TotalNodes(); node++ {
+			status, err := NodeHealth(cli, node, config.MeshName())
+			if err != nil {
+				return fmt.Errorf("error during the health check of the container %d: %v", node, err)
+			}
+			if health[node] != status {
+				health[node] = status
+				p.Send(healthMsg{node, status})
+			}
+			if isFailed(status) {
+				failed = append(failed, node)
+			}
+			if !isReady(status) {
+				notReady = append(notReady, node)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("containers %v stopped before being ready", failed)
+		}
+		if len(notReady) == 0 {
+			p.Send(resultMsg{time.Since(start), "All containers are ready"})
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("containers %v not ready after %v", notReady, timeout)
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
+// renderHealth renders the health status of the nodes, ten nodes per line
+func renderHealth(health map[int]string) string {
+	nodes := make([]int, 0, len(health))
+	for node := range health {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("Health (%s %s %s):\n", healthyStyle.Render("● ready"), startingStyle.Render("● starting"), unhealthyStyle.Render("● unhealthy")))
+	for i, node := range nodes {
+		style := startingStyle
+		switch {
+		case isReady(health[node]):
+			style = healthyStyle
+		case health[node] == types.Unhealthy || isFailed(health[node]):
+			style = unhealthyStyle
+		}
+		s.WriteString(fmt.Sprintf("%3d %s  ", node, style.Render("●")))
+		if i%10 == 9 {
+			s.WriteString("\n")
+		}
+	}
+	return s.String()
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const journalPollInterval = 500 * time.Millisecond // Interval between two reads of the journal while following it
+
+// Operations recorded in the event journal, besides the ones measured by operationDuration
+const (
+	OperationConnect         = "connect"
+	OperationDisconnect      = "disconnect"
+	OperationPause           = "pause"
+	OperationUnpause         = "unpause"
+	OperationKill            = "kill"
+	OperationDelayedRestart  = "delayed_restart"
+	OperationLatency         = "latency"
+	OperationPartition       = "partition"
+	OperationDropLink        = "drop_link"
+	OperationHealLinks       = "heal_links"
+	OperationUpdateResources = "update_resources"
+	OperationExec            = "exec"
+	OperationCopyIn          = "copy_in"
+	OperationCopyOut         = "copy_out"
+	OperationShell           = "shell"
+	OperationSnapshot        = "snapshot"
+	OperationRestore         = "restore"
+	OperationDeleteSnapshot  = "delete_snapshot"
+	OperationNodeExit        = "node_exit"
+	OperationNodeOOM         = "node_oom"
+	OperationNodeDisconnect  = "node_disconnect"
+)
+
+// Outcomes of the operations
+const (
+	OutcomeOK         = "ok"
+	OutcomeError      = "error"
+	OutcomeUnexpected = "unexpected" // Change of a node not made by ContainMesh
+)
+
+// Event is an entry of the event journal, an operation on the containers or the networks
+type Event struct {
+	Time     time.Time     `json:"Time"` // Start of the operation
+	Action   string        `json:"Action"`
+	Target   string        `json:"Target"` // Node ("3"), network ("net:1"), link ("3:1"), network pair ("0|1") or Docker name
+	Duration time.Duration `json:"Duration"`
+	Outcome  string        `json:"Outcome"`
+	Error    string        `json:"Error,omitempty"`
+	Details  string        `json:"Details,omitempty"`
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("[%s] %s", e.Time.Format("15:04:05.000"), e.Action)
+	if e.Target != "" {
+		s += " " + e.Target
+	}
+	s += fmt.Sprintf(" %s in %v", e.Outcome, e.Duration.Round(time.Millisecond))
+	if e.Details != "" {
+		s += " (" + e.Details + ")"
+	}
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+	return s
+}
+
+// eventJournal is the JSON lines file where this process appends its events
+// The file is opened in append mode, so the processes of the same environment can share it
+var eventJournal struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// EventJournalPath returns the path of the event journal given a pointer to the config struct, the one set with -events or a file next to the state
+func EventJournalPath(config *config.Config) string {
+	if *config.EventsPath != "" {
+		return *config.EventsPath
+	}
+	return filepath.Join(filepath.Dir(StateFilePath(config.MeshName())), containerNamePrefix(config.MeshName())+".events.jsonl")
+}
+
+// OpenEventJournal opens the file where the events are recorded, truncating it if requested
+// It returns an error if the file can't be opened
+func OpenEventJournal(path string, truncate bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating the directory of the event journal: %v", err)
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening the event journal: %v", err)
+	}
+	eventJournal.mutex.Lock()
+	defer eventJournal.mutex.Unlock()
+	if eventJournal.file != nil {
+		eventJournal.file.Close()
+	}
+	eventJournal.file = file
+	return nil
+}
+
+// CloseEventJournal closes the event journal, the next events are not recorded
+func CloseEventJournal() error {
+	eventJournal.mutex.Lock()
+	defer eventJournal.mutex.Unlock()
+	if eventJournal.file == nil {
+		return nil
+	}
+	err := eventJournal.file.Close()
+	eventJournal.file = nil
+	return err
+}
+
+// journalEvent appends an event to the journal, if it is open
+func journalEvent(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	eventJournal.mutex.Lock()
+	defer eventJournal.mutex.Unlock()
+	if eventJournal.file != nil {
+		// A single write per line keeps the lines of different processes separated
+		eventJournal.file.Write(append(data, '\n'))
+	}
+}
+
+// recordAction records an operation that started at the given time, err points to its outcome
+// It is meant to be deferred at the beginning of the operation
+func recordAction(action string, target string, start time.Time, err *error) {
+	recordActionDetails(action, target, "", start, *err)
+}
+
+// recordActionDetails records an operation that started at the given time with its details and its outcome
+func recordActionDetails(action string, target string, details string, start time.Time, err error) {
+	event := Event{Time: start, Action: action, Target: target, Duration: time.Since(start), Outcome: OutcomeOK, Details: details}
+	if err != nil {
+		event.Outcome = OutcomeError
+		event.Error = err.Error()
+	}
+	journalEvent(event)
+}
+
+// nodeTarget returns the target of an operation on a node
+func nodeTarget(nodeNumber int) string {
+	return strconv.Itoa(nodeNumber)
+}
+
+// linkTarget returns the target of an operation on a link
+func linkTarget(link Link) string {
+	return fmt.Sprintf("%d:%d", link.Node, link.To)
+}
+
+// ReadEvents calls the handler with the events of the journal, the oldest first
+// When following, it waits for the new events until the context is done, a truncated journal is read again from the beginning
+// It returns an error if the journal can't be read or the handler fails
+func ReadEvents(ctx context.Context, path string, follow bool, handler func(Event) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && follow {
+			// The journal is created by the first event
+			for os.IsNotExist(err) {
+				if !waitUntil(ctx, time.Now().Add(journalPollInterval)) {
+					return nil
+				}
+				file, err = os.Open(path)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error opening the event journal: %v", err)
+		}
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var offset int64
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading the event journal: %v", err)
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			// Keep an incomplete line until the rest of it is written
+			partial = append(partial, line...)
+			if !follow || !waitUntil(ctx, time.Now().Add(journalPollInterval)) {
+				return nil
+			}
+			if info, err := file.Stat(); err == nil && info.Size() < offset {
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					return fmt.Errorf("error reading the event journal: %v", err)
+				}
+				reader.Reset(file)
+				offset, partial = 0, nil
+			}
+			continue
+		}
+		line = append(partial, line...)
+		partial = nil
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+}
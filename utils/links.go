@@ -0,0 +1,366 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Link is a bridge node of a network that is also connected to another network
+type Link struct {
+	Node int `json:"Node"` // Bridge node
+	From int `json:"From"` // Network of the node
+	To   int `json:"To"`   // Network the node is connected to
+}
+
+// LinkFault describes a link fault injected in the virtual environment
+type LinkFault struct {
+	Fault string    `json:"Fault"` // partition or link-drop
+	Links []Link    `json:"Links"` // Links disconnected by the fault
+	Since time.Time `json:"Since"`
+}
+
+// LinkLatency is a latency set on the interface of a bridge node on the network it links, e.g. by the topology file
+type LinkLatency struct {
+	Link    Link          `json:"Link"`
+	Network string        `json:"Network"` // Name of the network, the interface is the one with the address of the node on it
+	Latency time.Duration `json:"Latency"`
+}
+
+// Link faults
+const (
+	FaultPartition  = "partition"
+	FaultLinkDrop   = "link-drop"
+	FaultDisconnect = "disconnect" // A node was disconnected outside ContainMesh
+)
+
+// Links returns the links of the virtual environment derived from the adjacency matrix, as created by CreateLinks
+func Links(config *config.Config) []Link {
+	var links []Link
+	for i := 0; i < len(config.NetMatrix); i++ {
+		for j := 0; j < len(config.NetMatrix[i]); j++ {
+			if config.NetMatrix[i][j] && i != j {
+				for k := 0; k < *config.NumLinks; k++ {
+					links = append(links, Link{Node: i**config.NumContainers + k, From: i, To: j})
+				}
+			}
+		}
+	}
+	return links
+}
+
+// networkName returns the name of a network given its number
+func networkName(config *config.Config, networkNumber int) string {
+	return config.NetworkPrefix() + strconv.Itoa(networkNumber)
+}
+
+// DisconnectLink disconnects a bridge node from the network it links
+// It returns an error if the disconnection fails
+func DisconnectLink(cli *client.Client, config *config.Config, link Link) (err error) {
+	defer recordAction(OperationDisconnect, linkTarget(link), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(link.Node, config.MeshName())
+	err = cli.NetworkDisconnect(context.Background(), networkName(config, link.To), containerName, true)
+	if err != nil {
+		return fmt.Errorf("error during the disconnection of the container %d from the network %d: %v", link.Node, link.To, err)
+	}
+	return nil
+}
+
+// ReconnectLink connects again a bridge node to the network it links
+// It returns an error if the connection fails
+func ReconnectLink(cli *client.Client, config *config.Config, link Link) (err error) {
+	defer recordAction(OperationConnect, linkTarget(link), time.Now(), &err)
+	containerName := ContainerNameFromNodeNumber(link.Node, config.MeshName())
+	err = cli.NetworkConnect(context.Background(), networkName(config, link.To), containerName, nil)
+	if err != nil {
+		return fmt.Errorf("error during the connection of the container %d to the network %d: %v", link.Node, link.To, err)
+	}
+	// The interface is new, it has no delay yet
+	return ReapplyLatencies(cli, link.Node, config.MeshName())
+}
+
+// PartitionLinks returns the links between two networks, in both directions
+func PartitionLinks(config *config.Config, network1 int, network2 int) []Link {
+	var links []Link
+	for _, link := range Links(config) {
+		if link.From == network1 && link.To == network2 || link.From == network2 && link.To == network1 {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// PartitionNetworks cuts all the links between two networks and records the partition
+// It returns an error if the networks are not linked or a disconnection fails
+func PartitionNetworks(cli *client.Client, config *config.Config, network1 int, network2 int) (err error) {
+	defer recordAction(OperationPartition, fmt.Sprintf("%d|%d", network1, network2), time.Now(), &err)
+	links := PartitionLinks(config, network1, network2)
+	if len(links) == 0 {
+		return fmt.Errorf("the networks %d and %d are not linked", network1, network2)
+	}
+	for _, link := range links {
+		if err := DisconnectLink(cli, config, link); err != nil {
+			return err
+		}
+	}
+	logf("Networks %d and %d partitioned successfully\n", network1, network2)
+	return recordLinkFault(config.MeshName(), LinkFault{Fault: FaultPartition, Links: links, Since: time.Now()})
+}
+
+// DropLink cuts a single link and records the fault
+// It returns an error if the disconnection fails
+func DropLink(cli *client.Client, config *config.Config, link Link) (err error) {
+	defer recordAction(OperationDropLink, linkTarget(link), time.Now(), &err)
+	if err := DisconnectLink(cli, config, link); err != nil {
+		return err
+	}
+	logf("Link of container %d from network %d to network %d dropped successfully\n", link.Node, link.From, link.To)
+	return recordLinkFault(config.MeshName(), LinkFault{Fault: FaultLinkDrop, Links: []Link{link}, Since: time.Now()})
+}
+
+// HealLinks reconnects the links cut by the faults that contain at least one of the given links and forgets those faults
+// It returns an error if a connection fails
+func HealLinks(cli *client.Client, config *config.Config, links []Link) (err error) {
+	start := time.Now()
+	healed := map[Link]bool{}
+	defer func() { recordActionDetails(OperationHealLinks, "", fmt.Sprintf("%d links", len(healed)), start, err) }()
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return err
+	}
+	for _, fault := range state.LinkFaults {
+		if !containsAnyLink(fault.Links, links) {
+			continue
+		}
+		for _, link := range fault.Links {
+			if healed[link] {
+				continue
+			}
+			if err := ReconnectLink(cli, config, link); err != nil {
+				return err
+			}
+			healed[link] = true
+		}
+	}
+	logf("%d links healed successfully\n", len(healed))
+	return UpdateState(config.MeshName(), func(state *MeshState) {
+		var faults []LinkFault
+		for _, fault := range state.LinkFaults {
+			if !containsAnyLink(fault.Links, links) {
+				faults = append(faults, fault)
+			}
+		}
+		state.LinkFaults = faults
+	})
+}
+
+// HealPartition reconnects all the links between two networks
+// It returns an error if a connection fails
+func HealPartition(cli *client.Client, config *config.Config, network1 int, network2 int) error {
+	return HealLinks(cli, config, PartitionLinks(config, network1, network2))
+}
+
+// HealAllLinks reconnects every link cut by a fault
+// It returns an error if a connection fails
+func HealAllLinks(cli *client.Client, config *config.Config) error {
+	return HealLinks(cli, config, Links(config))
+}
+
+// containsAnyLink reports whether the two lists of links have a link in common
+func containsAnyLink(links []Link, others []Link) bool {
+	for _, link := range links {
+		for _, other := range others {
+			if link == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// latencyScript returns the shell script that sets the delay of every interface of a node with netem, the latency of the node plus the one of the link of the interface
+// The interfaces of the links are found by their address with the ip command, the netem rule of an interface without delay is removed
+func latencyScript(latency time.Duration, links map[string]time.Duration) string {
+	delay := fmt.Sprintf("d=%d", latency.Microseconds())
+	if len(links) > 0 {
+		addresses := make([]string, 0, len(links))
+		for address := range links {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		cases := ""
+		for _, address := range addresses {
+			cases += fmt.Sprintf("%s) d=%d;; ", address, (latency + links[address]).Microseconds())
+		}
+		delay = fmt.Sprintf("case $(ip -o -4 addr show dev $i | awk '{split($4, a, \"/\"); print a[1]}') in %s*) d=%d;; esac", cases, latency.Microseconds())
+	}
+	return "for i in $(ls /sys/class/net); do [ $i = lo ] && continue; " + delay + "; " +
+		"if [ $d = 0 ]; then tc qdisc del dev $i root 2>/dev/null || true; else tc qdisc replace dev $i root netem delay ${d}us || exit 1; fi; done"
+}
+
+// nodeLatencies returns the latency of a node and the latencies of its links recorded in the state
+func nodeLatencies(state *MeshState, nodeNumber int) (time.Duration, []LinkLatency) {
+	var latency time.Duration
+	if fault, ok := state.Faults[nodeNumber]; ok && fault.Fault == FaultLatency {
+		latency = fault.Latency
+	}
+	var links []LinkLatency
+	for _, link := range state.Latencies {
+		if link.Link.Node == nodeNumber {
+			links = append(links, link)
+		}
+	}
+	return latency, links
+}
+
+// applyLatencies sets the delays of the interfaces of a node given its latency and the latencies of its links, the links that are cut are skipped
+// It returns an error if the node can't be inspected or the tc command fails
+func applyLatencies(cli *client.Client, nodeNumber int, meshName string, latency time.Duration, links []LinkLatency) error {
+	addresses := map[string]time.Duration{}
+	if len(links) > 0 {
+		containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+		info, err := cli.ContainerInspect(context.Background(), containerName)
+		if err != nil {
+			return fmt.Errorf("error during the inspection of the container %s: %v", containerName, err)
+		}
+		for _, link := range links {
+			if endpoint, ok := info.NetworkSettings.Networks[link.Network]; ok && endpoint.IPAddress != "" {
+				addresses[endpoint.IPAddress] = link.Latency
+			}
+		}
+	}
+	result, err := ExecInContainer(context.Background(), cli, nodeNumber, meshName, ShellCommand(latencyScript(latency, addresses)))
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("error setting the latency of the container %d (are ip and tc installed?): %s", nodeNumber, result.Stderr)
+	}
+	return nil
+}
+
+// ReapplyLatencies sets again the latencies of the state on a node whose interfaces were recreated, after a restart or a reconnection
+// It returns an error if the tc command fails
+func ReapplyLatencies(cli *client.Client, nodeNumber int, meshName string) error {
+	state, err := LoadState(meshName)
+	if err != nil {
+		return err
+	}
+	latency, links := nodeLatencies(state, nodeNumber)
+	if latency == 0 && len(links) == 0 {
+		return nil
+	}
+	return applyLatencies(cli, nodeNumber, meshName, latency, links)
+}
+
+// SetLatency adds a latency to all the interfaces of a node with tc netem, the image must provide the tc command
+// The latencies of the links of the node are kept and added to it, a latency of 0 removes the delay of the node
+// It returns an error if the tc command fails
+func SetLatency(cli *client.Client, nodeNumber int, meshName string, latency time.Duration) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationLatency, nodeTarget(nodeNumber), latency.String(), start, err) }()
+	state, err := LoadState(meshName)
+	if err != nil {
+		return err
+	}
+	_, links := nodeLatencies(state, nodeNumber)
+	if err := applyLatencies(cli, nodeNumber, meshName, latency, links); err != nil {
+		return err
+	}
+	if latency == 0 {
+		logf("Latency of container %d removed successfully\n", nodeNumber)
+		return clearFault(meshName, nodeNumber)
+	}
+	logf("Latency of container %d set to %v successfully\n", nodeNumber, latency)
+	return recordFault(meshName, FaultRecord{Node: nodeNumber, Fault: FaultLatency, Latency: latency, Since: time.Now()})
+}
+
+// SetLinkLatency adds a latency to the interface of a bridge node on the network it links with tc netem and records it, the image must provide the ip and tc commands
+// The latency of the node is kept and added to it, a latency of 0 removes the delay of the link
+// It returns an error if the node is not connected to the network or the tc command fails
+func SetLinkLatency(cli *client.Client, config *config.Config, link Link, latency time.Duration) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationLatency, linkTarget(link), latency.String(), start, err) }()
+	meshName := config.MeshName()
+	containerName := ContainerNameFromNodeNumber(link.Node, meshName)
+	info, err := cli.ContainerInspect(context.Background(), containerName)
+	if err != nil {
+		return fmt.Errorf("error during the inspection of the container %s: %v", containerName, err)
+	}
+	if endpoint, ok := info.NetworkSettings.Networks[networkName(config, link.To)]; !ok || endpoint.IPAddress == "" {
+		return fmt.Errorf("the container %d is not connected to the network %d", link.Node, link.To)
+	}
+	state, err := LoadState(meshName)
+	if err != nil {
+		return err
+	}
+	nodeLatency, links := nodeLatencies(state, link.Node)
+	links = slices.DeleteFunc(links, func(other LinkLatency) bool { return other.Link == link })
+	if latency != 0 {
+		links = append(links, LinkLatency{Link: link, Network: networkName(config, link.To), Latency: latency})
+	}
+	if err := applyLatencies(cli, link.Node, meshName, nodeLatency, links); err != nil {
+		return err
+	}
+	logf("Latency of the link of container %d to network %d set to %v successfully\n", link.Node, link.To, latency)
+	return UpdateState(meshName, func(state *MeshState) {
+		state.Latencies = slices.DeleteFunc(state.Latencies, func(other LinkLatency) bool { return other.Link == link })
+		if latency != 0 {
+			state.Latencies = append(state.Latencies, LinkLatency{Link: link, Network: networkName(config, link.To), Latency: latency})
+		}
+	})
+}
+
+// ApplyLinkProperties sets the properties of the links read from the topology file on their bridge nodes
+// It returns an error if a property can't be set
+func ApplyLinkProperties(cli *client.Client, config *config.Config) error {
+	for _, link := range Links(config) {
+		properties, ok := config.LinkPropertiesOf(link.From, link.To)
+		if !ok || properties.Latency == 0 {
+			continue
+		}
+		if err := SetLinkLatency(cli, config, link, properties.Latency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseNetworkPair parses a pair of networks written as "0|1"
+// It returns an error if the pair is not valid
+func ParseNetworkPair(config *config.Config, pair string) (int, int, error) {
+	first, second, ok := strings.Cut(pair, "|")
+	network1, err1 := strconv.Atoi(strings.TrimSpace(first))
+	network2, err2 := strconv.Atoi(strings.TrimSpace(second))
+	if !ok || err1 != nil || err2 != nil || network1 == network2 {
+		return 0, 0, fmt.Errorf("invalid network pair %q, it must be like 0|1", pair)
+	}
+	if network1 < 0 || network2 < 0 || network1 >= *config.NumNetworks || network2 >= *config.NumNetworks {
+		return 0, 0, fmt.Errorf("network pair %q out of range, the networks are numbered from 0 to %d", pair, *config.NumNetworks-1)
+	}
+	return network1, network2, nil
+}
+
+// ParseLink parses a link written as "3:1", the bridge node 3 connected to the network 1
+// It returns an error if the link doesn't exist
+func ParseLink(config *config.Config, text string) (Link, error) {
+	node, network, ok := strings.Cut(text, ":")
+	n, err1 := strconv.Atoi(strings.TrimSpace(node))
+	to, err2 := strconv.Atoi(strings.TrimSpace(network))
+	if !ok || err1 != nil || err2 != nil {
+		return Link{}, fmt.Errorf("invalid link %q, it must be like 3:1 (node 3 connected to the network 1)", text)
+	}
+	for _, link := range Links(config) {
+		if link.Node == n && link.To == to {
+			return link, nil
+		}
+	}
+	return Link{}, fmt.Errorf("the node %d is not a bridge to the network %d", n, to)
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const logReorderWindow = time.Second // Delay used to merge the lines of the nodes in timestamp order while following
+
+// nodeColors are the colors of the node prefixes, a node always gets the same color
+var nodeColors = []string{"39", "208", "42", "170", "226", "45", "203", "111", "154", "213"}
+
+// NodeStyle returns the stable style used to render the name of a node
+func NodeStyle(nodeNumber int) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(nodeColors[nodeNumber%len(nodeColors)])).Bold(true)
+}
+
+// LogLine is a line written by a node on its standard output or error
+type LogLine struct {
+	Node    int
+	Stream  string // stdout or stderr
+	Time    time.Time
+	Message string
+}
+
+// LogOptions selects the lines shown by StreamLogs
+type LogOptions struct {
+	Follow bool
+	Since  string         // Timestamp or relative time like 10m
+	Tail   string         // Number of lines from the end of the logs, all if empty
+	Grep   *regexp.Regexp // Only the lines that match are shown, all if nil
+}
+
+// logLineWriter splits the demultiplexed output of a node in timestamped lines
+type logLineWriter struct {
+	node    int
+	stream  string
+	grep    *regexp.Regexp
+	lines   chan<- LogLine
+	partial []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.emit(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// emit parses the timestamp added by the daemon at the beginning of the line and sends the line if it matches the filter
+func (w *logLineWriter) emit(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	timestamp, message, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		t, message = time.Now(), line
+	}
+	if w.grep != nil && !w.grep.MatchString(message) {
+		return
+	}
+	w.lines <- LogLine{Node: w.node, Stream: w.stream, Time: t, Message: message}
+}
+
+// flush sends the last line if it is not terminated by a newline
+func (w *logLineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.emit(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// followNodeLogs reads the logs of a node and sends its lines on the channel
+// It returns an error if the logs can't be read
+func followNodeLogs(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, options LogOptions, lines chan<- LogLine) error {
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	reader, err := cli.ContainerLogs(ctx, containerName, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     options.Follow,
+		Since:      options.Since,
+		Tail:       options.Tail,
+	})
+	if err != nil {
+		return fmt.Errorf("error reading the logs of the container %d: %v", nodeNumber, err)
+	}
+	defer reader.Close()
+	stdout := &logLineWriter{node: nodeNumber, stream: "stdout", grep: options.Grep, lines: lines}
+	stderr := &logLineWriter{node: nodeNumber, stream: "stderr", grep: options.Grep, lines: lines}
+	_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	stdout.flush()
+	stderr.flush()
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error reading the logs of the container %d: %v", nodeNumber, err)
+	}
+	return nil
+}
+
+// StreamLogs reads the logs of the nodes concurrently and calls the handler with the lines merged in timestamp order
+// While following, the lines are delayed by a short window to order the lines of different nodes, it stops when the context is done
+// It returns the errors of the nodes whose logs can't be read
+func StreamLogs(ctx context.Context, cli *client.Client, meshName string, nodes []int, options LogOptions, handler func(LogLine)) error {
+	lines := make(chan LogLine, 256)
+	var err error
+	go func() {
+		err = forNodes(nodes, func(node int) error {
+			return followNodeLogs(ctx, cli, node, meshName, options, lines)
+		})
+		close(lines)
+	}()
+
+	var pending []LogLine
+	// flush passes to the handler the pending lines older than the limit, in timestamp order
+	flush := func(limit time.Time) {
+		sort.SliceStable(pending, func(i, j int) bool { return pending[i].Time.Before(pending[j].Time) })
+		i := 0
+		for ; i < len(pending) && !pending[i].Time.After(limit); i++ {
+			handler(pending[i])
+		}
+		pending = pending[i:]
+	}
+	ticker := time.NewTicker(logReorderWindow / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush(time.Now().Add(24 * time.Hour))
+				return err
+			}
+			pending = append(pending, line)
+		case <-ticker.C:
+			if options.Follow {
+				flush(time.Now().Add(-logReorderWindow))
+			}
+		}
+	}
+}
+
+// LogPrinter writes the lines prefixed by the colored name of their node, and optionally without colors to a merged log file
+type LogPrinter struct {
+	Out    io.Writer
+	Merged io.Writer // Merged log file, disabled if nil
+	mutex  sync.Mutex
+}
+
+// Print writes a line
+func (p *LogPrinter) Print(line LogLine) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	name := fmt.Sprintf("node%-3d", line.Node)
+	marker := "|"
+	if line.Stream == "stderr" {
+		marker = "!"
+	}
+	fmt.Fprintf(p.Out, "%s %s %s\n", NodeStyle(line.Node).Render(name), marker, line.Message)
+	if p.Merged != nil {
+		fmt.Fprintf(p.Merged, "%s %s %s %s\n", line.Time.Format(time.RFC3339Nano), name, marker, line.Message)
+	}
+}
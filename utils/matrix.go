@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const defaultMatrixFile = "topology.yaml" // File where the matrix is saved if no yaml file is used
+
+var cursorStyle = lipgloss.NewStyle().Reverse(true)
+
+// matrixEditor edits the adjacency matrix of the networks, the cell (i, j) links the network i to the network j
+type matrixEditor struct {
+	matrix    [][]bool
+	row       int
+	col       int
+	topology  int // Index of the next generated topology
+	path      textinput.Model
+	saving    bool
+	message   string
+	cancelled bool
+}
+
+func newMatrixEditor(matrix [][]bool, path string) matrixEditor {
+	input := textinput.New()
+	input.Placeholder = defaultMatrixFile
+	input.SetValue(path)
+	m := matrixEditor{matrix: matrix, path: input}
+	if len(matrix) > 1 {
+		m.col = 1
+	}
+	return m
+}
+
+func (m matrixEditor) Init() tea.Cmd {
+	return nil
+}
+
+// set changes a cell, the diagonal is always empty
+func (m *matrixEditor) set(i int, j int, value bool) {
+	if i != j {
+		m.matrix[i][j] = value
+	}
+}
+
+func (m matrixEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if m.saving {
+		switch key.String() {
+		case "esc":
+			m.saving = false
+			m.path.Blur()
+		case "enter":
+			m.saving = false
+			m.path.Blur()
+			path := strings.TrimSpace(m.path.Value())
+			if path == "" {
+				path = defaultMatrixFile
+			}
+			if err := config.SaveNetMatrix(path, m.matrix); err != nil {
+				m.message = errorStyle.Render(err.Error())
+			} else {
+				m.message = "Matrix saved to " + path
+			}
+		default:
+			var cmd tea.Cmd
+			m.path, cmd = m.path.Update(key)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	n := len(m.matrix)
+	m.message = ""
+	switch key.String() {
+	case "ctrl+c", "esc", "q":
+		m.cancelled = true
+		return m, tea.Quit
+	case "enter":
+		return m, tea.Quit
+	case "up", "k":
+		m.row = (m.row + n - 1) % n
+	case "down", "j":
+		m.row = (m.row + 1) % n
+	case "left", "h":
+		m.col = (m.col + n - 1) % n
+	case "right", "l":
+		m.col = (m.col + 1) % n
+	case " ", "x":
+		m.set(m.row, m.col, !m.matrix[m.row][m.col])
+	case "s":
+		value := !m.matrix[m.row][m.col]
+		m.set(m.row, m.col, value)
+		m.set(m.col, m.row, value)
+	case "m":
+		// Make every link bidirectional
+		for i := range m.matrix {
+			for j := range m.matrix[i] {
+				if m.matrix[i][j] {
+					m.set(j, i, true)
+				}
+			}
+		}
+	case "g":
+		name := config.Topologies[m.topology]
+		m.topology = (m.topology + 1) % len(config.Topologies)
+		matrix, err := config.GenerateTopology(name, n)
+		if err != nil {
+			m.message = errorStyle.Render(err.Error())
+			break
+		}
+		m.matrix = matrix
+		m.message = "Loaded the " + name + " topology"
+	case "w":
+		m.saving = true
+		return m, m.path.Focus()
+	}
+	return m, nil
+}
+
+func (m matrixEditor) View() string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("Adjacency matrix of the networks") + "\n")
+	s.WriteString("The bridge nodes of the row network join the column network, one way links are highlighted\n\n")
+	width := len(fmt.Sprint(len(m.matrix)-1)) + 1
+	s.WriteString(strings.Repeat(" ", width+1))
+	for j := range m.matrix {
+		s.WriteString(fmt.Sprintf("%*d", width, j))
+	}
+	s.WriteString("\n")
+	for i, row := range m.matrix {
+		s.WriteString(fmt.Sprintf("%*d ", width, i))
+		for j, linked := range row {
+			cell := "0"
+			switch {
+			case i == j:
+				cell = "X"
+			case linked:
+				cell = "1"
+			}
+			cell = strings.Repeat(" ", width-1) + cell
+			if i == m.row && j == m.col {
+				cell = strings.Repeat(" ", width-1) + cursorStyle.Render(strings.TrimSpace(cell))
+			} else if i != j && linked != m.matrix[j][i] {
+				cell = startingStyle.Render(cell)
+			}
+			s.WriteString(cell)
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	switch {
+	case m.saving:
+		s.WriteString("Save to: " + m.path.View() + "  " + helpStyle.UnsetMargins().Render("(enter to confirm, esc to cancel)"))
+	default:
+		if m.message != "" {
+			s.WriteString(m.message + "\n")
+		}
+		s.WriteString(helpStyle.UnsetMargins().Render("arrows move • space toggle • s toggle both ways • m make symmetric • g next generated topology (" + config.Topologies[m.topology] + ") • w save • enter confirm • q cancel"))
+	}
+	return appStyle.Render(s.String())
+}
+
+// EditMatrix opens the editor of the adjacency matrix and sets the edited matrix in the config struct
+// The editor starts from the matrix of the config struct if it has the right size, otherwise from an empty matrix
+// It returns an error if the editor can't be displayed or the edit is cancelled
+func EditMatrix(cfg *config.Config) error {
+	numNetworks := *cfg.NumNetworks
+	matrix, _ := config.GenerateTopology("empty", numNetworks)
+	if len(cfg.NetMatrix) == numNetworks {
+		for i := range matrix {
+			copy(matrix[i], cfg.NetMatrix[i])
+		}
+	}
+	path := *cfg.YamlFilePath
+	if path == "" {
+		path = defaultMatrixFile
+	}
+	m, err := tea.NewProgram(newMatrixEditor(matrix, path)).Run()
+	if err != nil {
+		return fmt.Errorf("error during the execution of the matrix editor: %v", err)
+	}
+	editor, ok := m.(matrixEditor)
+	if !ok {
+		return fmt.Errorf("error during the type assertion of the matrix editor model")
+	}
+	if editor.cancelled {
+		return fmt.Errorf("the creation of the adjacency matrix was cancelled")
+	}
+	cfg.NetMatrix = editor.matrix
+	return nil
+}
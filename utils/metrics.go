@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"net/http"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// operationDuration measures the Docker operations run by ContainMesh, from the same durations shown by the spinner
+var operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "containmesh_operation_duration_seconds",
+	Help:    "Duration of the operations on the containers and networks.",
+	Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"operation"})
+
+// Operations measured by operationDuration
+const (
+	OperationCreateContainer = "create_container"
+	OperationRemoveContainer = "remove_container"
+	OperationStartContainer  = "start_container"
+	OperationStopContainer   = "stop_container"
+	OperationNodeReady       = "node_ready"
+	OperationCreateNetwork   = "create_network"
+	OperationRemoveNetwork   = "remove_network"
+	OperationLinkNetworks    = "link_networks"
+)
+
+// observeOperation records the duration of an operation
+func observeOperation(operation string, duration time.Duration) {
+	operationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// sendResult shows the outcome of an operation in the spinner and records its duration
+func sendResult(p *tea.Program, operation string, duration time.Duration, msg string) {
+	observeOperation(operation, duration)
+	// The operations run from the command line have no spinner
+	if p != nil {
+		p.Send(resultMsg{duration, msg})
+	}
+}
+
+var (
+	nodeUpDesc = prometheus.NewDesc("containmesh_node_up",
+		"Whether the node is running.", []string{"node", "network"}, nil)
+	nodePausedDesc = prometheus.NewDesc("containmesh_node_paused",
+		"Whether the node is paused.", []string{"node", "network"}, nil)
+	nodeCPUDesc = prometheus.NewDesc("containmesh_node_cpu_percent",
+		"CPU usage of the node as a percentage of one CPU.", []string{"node", "network"}, nil)
+	nodeMemoryDesc = prometheus.NewDesc("containmesh_node_memory_bytes",
+		"Memory used by the node, without the page cache.", []string{"node", "network"}, nil)
+	nodeMemoryLimitDesc = prometheus.NewDesc("containmesh_node_memory_limit_bytes",
+		"Memory limit of the node.", []string{"node", "network"}, nil)
+	nodeNetRxDesc = prometheus.NewDesc("containmesh_node_network_receive_bytes_total",
+		"Bytes received by the node on every network.", []string{"node", "network"}, nil)
+	nodeNetTxDesc = prometheus.NewDesc("containmesh_node_network_transmit_bytes_total",
+		"Bytes sent by the node on every network.", []string{"node", "network"}, nil)
+	networkLinksDesc = prometheus.NewDesc("containmesh_network_links",
+		"Links from the bridge nodes of the network to the other networks.", []string{"network"}, nil)
+	networkLinksDownDesc = prometheus.NewDesc("containmesh_network_links_down",
+		"Links of the network disconnected by a partition or a link drop.", []string{"network"}, nil)
+	faultsInjectedDesc = prometheus.NewDesc("containmesh_faults_injected_total",
+		"Faults injected in the nodes and links since the creation of the environment.", []string{"fault"}, nil)
+	faultsActiveDesc = prometheus.NewDesc("containmesh_faults_active",
+		"Faults currently active.", []string{"fault"}, nil)
+)
+
+// meshCollector reads the state of the virtual environment at every scrape
+type meshCollector struct {
+	cli    *client.Client
+	config *config.Config
+	stats  *StatsCollector
+}
+
+func (c meshCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{nodeUpDesc, nodePausedDesc, nodeCPUDesc, nodeMemoryDesc, nodeMemoryLimitDesc,
+		nodeNetRxDesc, nodeNetTxDesc, networkLinksDesc, networkLinksDownDesc, faultsInjectedDesc, faultsActiveDesc} {
+		ch <- desc
+	}
+}
+
+func (c meshCollector) Collect(ch chan<- prometheus.Metric) {
+	statuses, err := GetNodesStatus(c.cli, c.config)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(nodeUpDesc, err)
+		return
+	}
+	state, err := LoadState(c.config.MeshName())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(faultsInjectedDesc, err)
+		return
+	}
+	// boolValue converts a condition into a gauge value
+	boolValue := func(condition bool) float64 {
+		if condition {
+			return 1
+		}
+		return 0
+	}
+	active := map[string]int{}
+	for _, status := range statuses {
+		labels := []string{strconv.Itoa(status.Node), strconv.Itoa(status.Node / *c.config.NumContainers)}
+		ch <- prometheus.MustNewConstMetric(nodeUpDesc, prometheus.GaugeValue, boolValue(status.State == NodeRunning), labels...)
+		ch <- prometheus.MustNewConstMetric(nodePausedDesc, prometheus.GaugeValue, boolValue(status.State == NodePaused), labels...)
+		if status.Fault != nil {
+			active[status.Fault.Fault]++
+		}
+		stats, ok := c.stats.Latest(status.Node)
+		if !ok || status.State != NodeRunning {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(nodeCPUDesc, prometheus.GaugeValue, stats.CPU, labels...)
+		ch <- prometheus.MustNewConstMetric(nodeMemoryDesc, prometheus.GaugeValue, float64(stats.Memory), labels...)
+		ch <- prometheus.MustNewConstMetric(nodeMemoryLimitDesc, prometheus.GaugeValue, float64(stats.MemoryLimit), labels...)
+		ch <- prometheus.MustNewConstMetric(nodeNetRxDesc, prometheus.CounterValue, float64(stats.NetRx), labels...)
+		ch <- prometheus.MustNewConstMetric(nodeNetTxDesc, prometheus.CounterValue, float64(stats.NetTx), labels...)
+	}
+
+	links := make([]int, *c.config.NumNetworks)
+	down := make([]int, *c.config.NumNetworks)
+	for _, link := range Links(c.config) {
+		links[link.From]++
+	}
+	for _, fault := range state.LinkFaults {
+		active[fault.Fault]++
+		for _, link := range fault.Links {
+			down[link.From]++
+		}
+	}
+	for network := range links {
+		ch <- prometheus.MustNewConstMetric(networkLinksDesc, prometheus.GaugeValue, float64(links[network]), strconv.Itoa(network))
+		ch <- prometheus.MustNewConstMetric(networkLinksDownDesc, prometheus.GaugeValue, float64(down[network]), strconv.Itoa(network))
+	}
+
+	for _, fault := range []string{FaultStop, FaultPause, FaultKill, FaultRestart, FaultLatency, FaultCrash, FaultOOM, FaultPartition, FaultLinkDrop, FaultDisconnect} {
+		ch <- prometheus.MustNewConstMetric(faultsInjectedDesc, prometheus.CounterValue, float64(state.FaultCounts[fault]), fault)
+		ch <- prometheus.MustNewConstMetric(faultsActiveDesc, prometheus.GaugeValue, float64(active[fault]), fault)
+	}
+}
+
+// NewMetricsHandler creates the handler of the Prometheus metrics given a pointer to a Docker client, a pointer to the config struct and the running stats collector
+func NewMetricsHandler(cli *client.Client, config *config.Config, stats *StatsCollector) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		operationDuration,
+		meshCollector{cli, config, stats},
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Output is where the operations on the nodes report their outcome, the standard output by default
+// The dashboard redirects it to its event log
+var Output io.Writer = os.Stdout
+
+var outputMutex sync.Mutex
+
+// logf writes a progress message of an operation to the Output
+func logf(format string, args ...any) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprintf(Output, format, args...)
+}
+
+// SetOutput changes the Output and returns the previous one
+func SetOutput(w io.Writer) io.Writer {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	previous := Output
+	Output = w
+	return previous
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// Labels set on the containers and the networks of the virtual environments
+const (
+	LabelProject = "containmesh.project" // Project name, empty if none is set
+	LabelMesh    = "containmesh.mesh"    // Mesh name, the image name prefixed by the project name and the separator
+	LabelImage   = "containmesh.image"   // Main image of the environment
+	LabelNode    = "containmesh.node"    // Number of the node
+	LabelNetwork = "containmesh.network" // Number of the network
+)
+
+// meshLabels returns the labels shared by the containers and the networks of the virtual environment
+func meshLabels(config *config.Config) map[string]string {
+	return map[string]string{LabelProject: config.Project.Name, LabelMesh: config.MeshName(), LabelImage: *config.ImageName}
+}
+
+// ownedBy reports whether a container or a network belongs to the virtual environment, by its labels or, for the ones created without labels, by its exact name
+func ownedBy(labels map[string]string, name string, project string, mesh string, pattern *regexp.Regexp) bool {
+	if owner, ok := labels[LabelMesh]; ok {
+		return owner == mesh && labels[LabelProject] == project
+	}
+	return pattern.MatchString(strings.TrimPrefix(name, "/"))
+}
+
+// numberedNamePattern returns the regular expression that matches a prefix followed by a number
+func numberedNamePattern(prefix string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `\d+$`)
+}
+
+// subnetAllocator hands out the subnets of a pool that are not used by the networks of the host
+type subnetAllocator struct {
+	pool netip.Prefix
+	size int
+	next netip.Addr
+	used []netip.Prefix
+}
+
+// newSubnetAllocator creates an allocator given a pointer to a Docker client and the project settings, the subnets of the existing networks are skipped
+// It returns an error if the pool is not valid or the networks can't be listed
+func newSubnetAllocator(cli *client.Client, settings config.ProjectSettings) (*subnetAllocator, error) {
+	pool, err := settings.Pool()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := cli.NetworkList(context.Background(), network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the networks: %v", err)
+	}
+	allocator := &subnetAllocator{pool: pool, size: settings.Size(), next: pool.Addr()}
+	for _, network := range networks {
+		for _, ipam := range network.IPAM.Config {
+			if subnet, err := netip.ParsePrefix(ipam.Subnet); err == nil {
+				allocator.used = append(allocator.used, subnet)
+			}
+		}
+	}
+	return allocator, nil
+}
+
+// allocate returns the next subnet of the pool that doesn't overlap a used one
+// It returns an error if the pool is exhausted
+func (a *subnetAllocator) allocate() (netip.Prefix, error) {
+	step := uint32(1) << (32 - a.size)
+	for a.next.IsValid() && a.pool.Contains(a.next) {
+		subnet := netip.PrefixFrom(a.next, a.size)
+		bytes := a.next.As4()
+		if value := binary.BigEndian.Uint32(bytes[:]) + step; value != 0 {
+			binary.BigEndian.PutUint32(bytes[:], value)
+			a.next = netip.AddrFrom4(bytes)
+		} else {
+			a.next = netip.Addr{}
+		}
+		free := true
+		for _, used := range a.used {
+			if used.Overlaps(subnet) {
+				free = false
+				break
+			}
+		}
+		if free {
+			a.used = append(a.used, subnet)
+			return subnet, nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("no free /%d subnet left in the pool %s, set another one with -subnet-pool", a.size, a.pool)
+}
+
+// isSubnetOverlap reports whether the creation of a network failed because its subnet is already used
+func isSubnetOverlap(err error) bool {
+	return strings.Contains(err.Error(), "overlaps")
+}
+
+// EnvironmentInfo describes a virtual environment found on the host
+type EnvironmentInfo struct {
+	Project  string    `json:"Project"`
+	Mesh     string    `json:"Mesh"`
+	Image    string    `json:"Image"`
+	Nodes    int       `json:"Nodes"`
+	Running  int       `json:"Running"`
+	Networks []string  `json:"Networks"`
+	Subnets  []string  `json:"Subnets"`
+	Created  time.Time `json:"Created"`
+}
+
+// ListEnvironments returns the virtual environments of the host, found by the labels of their containers and networks, sorted by project and mesh name
+// It returns an error if the containers or the networks can't be listed
+func ListEnvironments(cli *client.Client) ([]EnvironmentInfo, error) {
+	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelMesh)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the containers: %v", err)
+	}
+	networks, err := cli.NetworkList(context.Background(), network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelMesh)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the networks: %v", err)
+	}
+	environments := map[string]*EnvironmentInfo{}
+	environment := func(labels map[string]string) *EnvironmentInfo {
+		mesh := labels[LabelMesh]
+		if environments[mesh] == nil {
+			environments[mesh] = &EnvironmentInfo{Project: labels[LabelProject], Mesh: mesh, Image: labels[LabelImage], Networks: []string{}, Subnets: []string{}}
+		}
+		return environments[mesh]
+	}
+	for _, c := range containers {
+		info := environment(c.Labels)
+		info.Nodes++
+		if c.State == "running" {
+			info.Running++
+		}
+		if created := time.Unix(c.Created, 0); info.Created.IsZero() || created.Before(info.Created) {
+			info.Created = created
+		}
+	}
+	// The networks are sorted by number
+	sort.Slice(networks, func(i, j int) bool {
+		a, _ := strconv.Atoi(networks[i].Labels[LabelNetwork])
+		b, _ := strconv.Atoi(networks[j].Labels[LabelNetwork])
+		return a < b
+	})
+	for _, n := range networks {
+		info := environment(n.Labels)
+		info.Networks = append(info.Networks, n.Name)
+		for _, ipam := range n.IPAM.Config {
+			info.Subnets = append(info.Subnets, ipam.Subnet)
+		}
+	}
+	list := make([]EnvironmentInfo, 0, len(environments))
+	for _, info := range environments {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Project != list[j].Project {
+			return list[i].Project < list[j].Project
+		}
+		return list[i].Mesh < list[j].Mesh
+	})
+	return list, nil
+}
+
+// PrintEnvironments prints the virtual environments of the host as a table
+func PrintEnvironments(environments []EnvironmentInfo) {
+	if len(environments) == 0 {
+		fmt.Println("No virtual environment on this host")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tIMAGE\tNODES\tRUNNING\tNETWORKS\tSUBNETS\tCREATED")
+	for _, env := range environments {
+		project := env.Project
+		if project == "" {
+			project = "-"
+		}
+		created := "-"
+		if !env.Created.IsZero() {
+			created = env.Created.Format(time.DateTime)
+		}
+		// The first subnet is enough to recognize the environment
+		subnets := "-"
+		if len(env.Subnets) > 0 {
+			subnets = env.Subnets[0]
+		}
+		if len(env.Subnets) > 1 {
+			subnets += fmt.Sprintf(" (+%d)", len(env.Subnets)-1)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n", project, env.Image, env.Nodes, env.Running, len(env.Networks), subnets, created)
+	}
+	w.Flush()
+}
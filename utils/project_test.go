@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestOwnedBy(t *testing.T) {
+	pattern := numberedNamePattern(containerNamePrefix("test_name"))
+	tests := []struct {
+		name   string
+		labels map[string]string
+		object string
+		want   bool
+	}{
+		{name: "same mesh without project", labels: map[string]string{LabelProject: "", LabelMesh: "test_name"}, object: "/cont_test_name0", want: true},
+		{name: "same mesh string from another project", labels: map[string]string{LabelProject: "test", LabelMesh: "test_name"}, object: "/cont_test_name0"},
+		{name: "project and image", labels: map[string]string{LabelProject: "test", LabelMesh: "test_-name"}, object: "/cont_test_-name0"},
+		{name: "unlabeled with the exact name", labels: map[string]string{}, object: "/cont_test_name3", want: true},
+		{name: "unlabeled with another name", labels: map[string]string{}, object: "/cont_test_name3x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ownedBy(test.labels, test.object, "", "test_name", pattern); got != test.want {
+				t.Errorf("ownedBy() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSubnetAllocator(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.ProjectSettings
+		used     []string
+		count    int
+		want     []string
+	}{
+		{
+			name:     "default pool and size",
+			settings: config.ProjectSettings{},
+			count:    3,
+			want:     []string{"10.200.0.0/24", "10.200.1.0/24", "10.200.2.0/24"},
+		},
+		{
+			name:     "used prefixes are skipped",
+			settings: config.ProjectSettings{},
+			used:     []string{"10.200.0.0/24", "10.200.1.128/25", "10.200.3.0/24", "172.17.0.0/16"},
+			count:    3,
+			want:     []string{"10.200.2.0/24", "10.200.4.0/24", "10.200.5.0/24"},
+		},
+		{
+			name:     "a larger used prefix covers many subnets",
+			settings: config.ProjectSettings{SubnetPool: "10.0.0.0/8", SubnetSize: 16},
+			used:     []string{"10.0.0.0/14"},
+			count:    2,
+			want:     []string{"10.4.0.0/16", "10.5.0.0/16"},
+		},
+		{
+			name:     "non default pool and size",
+			settings: config.ProjectSettings{SubnetPool: "192.168.8.0/22", SubnetSize: 26},
+			count:    3,
+			want:     []string{"192.168.8.0/26", "192.168.8.64/26", "192.168.8.128/26"},
+		},
+		{
+			name:     "pool exhausted",
+			settings: config.ProjectSettings{SubnetPool: "10.1.0.0/23", SubnetSize: 24},
+			used:     []string{"10.1.1.0/24"},
+			count:    2,
+			want:     []string{"10.1.0.0/24"},
+		},
+		{
+			name:     "pool at the end of the address space",
+			settings: config.ProjectSettings{SubnetPool: "255.255.255.0/24", SubnetSize: 25},
+			count:    3,
+			want:     []string{"255.255.255.0/25", "255.255.255.128/25"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool, err := test.settings.Pool()
+			if err != nil {
+				t.Fatal(err)
+			}
+			allocator := &subnetAllocator{pool: pool, size: test.settings.Size(), next: pool.Addr()}
+			for _, used := range test.used {
+				allocator.used = append(allocator.used, netip.MustParsePrefix(used))
+			}
+			var got []string
+			for i := 0; i < test.count; i++ {
+				subnet, err := allocator.allocate()
+				if err != nil {
+					break
+				}
+				got = append(got, subnet.String())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("allocate() = %v, want %v", got, test.want)
+			}
+			// Once exhausted the pool stays exhausted
+			if len(got) < test.count {
+				if _, err := allocator.allocate(); err == nil {
+					t.Errorf("allocate() error = nil after the pool was exhausted")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// DockerResources converts the resource settings of a node into the Docker resources
+// It returns an error if the settings are not valid
+func DockerResources(settings config.ResourceSettings) (container.Resources, error) {
+	if err := settings.Validate(); err != nil {
+		return container.Resources{}, err
+	}
+	return dockerResources(settings)
+}
+
+// dockerResources converts the resource settings into the Docker resources without checking their consistency
+// It returns an error if a size is not valid
+func dockerResources(settings config.ResourceSettings) (container.Resources, error) {
+	memory, swap, err := settings.MemoryBytes()
+	if err != nil {
+		return container.Resources{}, err
+	}
+	resources := container.Resources{
+		CPUQuota:    settings.CPUQuota,
+		CPUPeriod:   settings.CPUPeriod,
+		CPUShares:   settings.CPUShares,
+		CpusetCpus:  settings.CPUSetCPUs,
+		Memory:      memory,
+		MemorySwap:  swap,
+		BlkioWeight: settings.BlkioWeight,
+	}
+	if settings.PidsLimit != 0 {
+		pidsLimit := settings.PidsLimit
+		resources.PidsLimit = &pidsLimit
+	}
+	for _, u := range settings.Ulimits {
+		resources.Ulimits = append(resources.Ulimits, &container.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+	return resources, nil
+}
+
+// UpdateContainerResources changes the resource limits of a running container given its node number and the new settings
+// Only the non zero settings are changed, the ulimits can be set only at creation time
+// It returns an error if the update fails
+func UpdateContainerResources(cli *client.Client, nodeNumber int, meshName string, settings config.ResourceSettings) (err error) {
+	defer recordAction(OperationUpdateResources, nodeTarget(nodeNumber), time.Now(), &err)
+	if len(settings.Ulimits) > 0 {
+		return fmt.Errorf("the ulimits of a running container can't be changed")
+	}
+	if err := settings.ValidateUpdate(); err != nil {
+		return fmt.Errorf("error in the resource settings: %v", err)
+	}
+	resources, err := dockerResources(settings)
+	if err != nil {
+		return fmt.Errorf("error in the resource settings: %v", err)
+	}
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	containerID, err := GetContainerID(cli, containerName)
+	if err != nil {
+		return fmt.Errorf("error during the retrieval of the container ID: %v", err)
+	}
+	// A swap limit alone is checked against the memory limit the container already has
+	if resources.MemorySwap > 0 && resources.Memory == 0 {
+		inspect, err := cli.ContainerInspect(context.Background(), containerID)
+		if err != nil {
+			return fmt.Errorf("error during the inspection of the container %s: %v", containerName, err)
+		}
+		if memory := inspect.HostConfig.Memory; memory == 0 || resources.MemorySwap < memory {
+			return fmt.Errorf("error in the resource settings: the memory swap limit must be greater than or equal to the memory limit of the container %s", containerName)
+		}
+	}
+	_, err = cli.ContainerUpdate(context.Background(), containerID, container.UpdateConfig{Resources: resources})
+	if err != nil {
+		return fmt.Errorf("error during the update of the container %s: %v", containerName, err)
+	}
+	logf("Resources of container %d updated successfully\n", nodeNumber)
+	return nil
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/docker/docker/client"
+)
+
+const defaultExecTimeout = time.Minute // Maximum duration of an exec step without timeout
+
+var (
+	passStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Bold(true)
+	failStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+)
+
+// StepResult is the outcome of a scenario step
+type StepResult struct {
+	Step     int           `json:"Step"`
+	At       time.Duration `json:"At"`
+	Action   string        `json:"Action"`
+	Target   string        `json:"Target,omitempty"`
+	Started  time.Time     `json:"Started"`
+	Duration time.Duration `json:"Duration"`
+	Passed   bool          `json:"Passed"`
+	Error    string        `json:"Error,omitempty"`
+	Results  []ExecResult  `json:"Results,omitempty"` // Output of the exec steps
+}
+
+// ScenarioReport is the outcome of a scenario
+type ScenarioReport struct {
+	Name     string        `json:"Name"`
+	Passed   bool          `json:"Passed"`
+	Started  time.Time     `json:"Started"`
+	Duration time.Duration `json:"Duration"`
+	Steps    []StepResult  `json:"Steps"`
+}
+
+// runScenarioStep performs the action of a step on the virtual environment
+// It returns the output of the exec steps and an error if the action fails or an assertion is not satisfied
+func runScenarioStep(ctx context.Context, cli *client.Client, config *config.Config, step config.ScenarioStep) ([]ExecResult, error) {
+	meshName := config.MeshName()
+	switch step.Action {
+	case "partition":
+		network1, network2, err := ParseNetworkPair(config, step.Target)
+		if err != nil {
+			return nil, err
+		}
+		return nil, PartitionNetworks(cli, config, network1, network2)
+	case "link-drop":
+		link, err := ParseLink(config, step.Target)
+		if err != nil {
+			return nil, err
+		}
+		return nil, DropLink(cli, config, link)
+	case "heal":
+		if step.Target == "" || step.Target == "all" {
+			return nil, HealAll(cli, config)
+		}
+		if strings.Contains(step.Target, "|") {
+			network1, network2, err := ParseNetworkPair(config, step.Target)
+			if err != nil {
+				return nil, err
+			}
+			return nil, HealPartition(cli, config, network1, network2)
+		}
+		link, err := ParseLink(config, step.Target)
+		if err != nil {
+			return nil, err
+		}
+		return nil, HealLinks(cli, config, []Link{link})
+	}
+
+	nodes, err := config.ParseNodes(step.Target)
+	if err != nil {
+		return nil, err
+	}
+	switch step.Action {
+	case "stop":
+		return nil, forNodes(nodes, func(node int) error { return StopContainer(cli, node, meshName) })
+	case "start":
+		return nil, forNodes(nodes, func(node int) error { return RestartContainer(cli, node, meshName) })
+	case "pause":
+		return nil, forNodes(nodes, func(node int) error { return PauseContainer(cli, node, meshName) })
+	case "unpause":
+		return nil, forNodes(nodes, func(node int) error { return UnpauseContainer(cli, node, meshName) })
+	case "kill":
+		return nil, forNodes(nodes, func(node int) error { return KillContainer(cli, node, meshName, step.Signal) })
+	case "restart":
+		return nil, forNodes(nodes, func(node int) error { return RestartContainerWithDelay(cli, node, meshName, step.Delay) })
+	case "latency":
+		return nil, forNodes(nodes, func(node int) error { return SetLatency(cli, node, meshName, step.Latency) })
+	case "exec":
+		return runExecStep(ctx, cli, config, step, nodes)
+	}
+	return nil, fmt.Errorf("unknown action %q", step.Action)
+}
+
+// runExecStep runs the command of an exec step on the nodes and checks the exit code (0 if not set) and the output
+// It returns the output of every node and an error if an assertion is not satisfied on a node
+func runExecStep(ctx context.Context, cli *client.Client, config *config.Config, step config.ScenarioStep, nodes []int) ([]ExecResult, error) {
+	timeout := step.Timeout
+	if timeout == 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	expected := 0
+	if step.ExitCode != nil {
+		expected = *step.ExitCode
+	}
+	results := make([]ExecResult, len(nodes))
+	index := map[int]int{}
+	for i, node := range nodes {
+		index[node] = i
+	}
+	err := forNodes(nodes, func(node int) error {
+		result, err := ExecInContainer(ctx, cli, node, config.MeshName(), ShellCommand(step.Command))
+		results[index[node]] = result
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != expected {
+			return fmt.Errorf("node %d exited with %d instead of %d", node, result.ExitCode, expected)
+		}
+		if step.Output != "" && !strings.Contains(result.Stdout+result.Stderr, step.Output) {
+			return fmt.Errorf("the output of node %d doesn't contain %q", node, step.Output)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// RunScenario runs the steps of a scenario at their times and checks their outcome, a failed step doesn't stop the scenario
+// It returns the report of the scenario, the steps not reached when the context is done are reported as failed
+func RunScenario(ctx context.Context, cli *client.Client, config *config.Config, scenario *config.Scenario) *ScenarioReport {
+	report := &ScenarioReport{Name: scenario.Name, Passed: true, Started: time.Now()}
+	for i, step := range scenario.Steps {
+		result := StepResult{Step: i + 1, At: step.At, Action: step.Action, Target: step.Target}
+		if !waitUntil(ctx, report.Started.Add(step.At)) {
+			result.Error = "scenario interrupted"
+		} else {
+			result.Started = time.Now()
+			fmt.Printf("Step %d at %v: %s %s\n", result.Step, step.At, step.Action, step.Target)
+			var err error
+			result.Results, err = runScenarioStep(ctx, cli, config, step)
+			result.Duration = time.Since(result.Started)
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}
+		result.Passed = result.Error == ""
+		report.Passed = report.Passed && result.Passed
+		report.Steps = append(report.Steps, result)
+	}
+	report.Duration = time.Since(report.Started)
+	return report
+}
+
+// Print prints the report with a line for every step and the output of the failed exec steps
+func (r *ScenarioReport) Print() {
+	fmt.Printf("\nScenario %s\n", r.Name)
+	for _, step := range r.Steps {
+		outcome := passStyle.Render("PASS")
+		if !step.Passed {
+			outcome = failStyle.Render("FAIL")
+		}
+		fmt.Printf("%s %3d %8v %-10s %-10s %v\n", outcome, step.Step, step.At, step.Action, step.Target, step.Duration.Truncate(time.Millisecond))
+		if step.Passed {
+			continue
+		}
+		fmt.Printf("         %s\n", strings.ReplaceAll(step.Error, "\n", "\n         "))
+		for _, result := range step.Results {
+			fmt.Printf("         node %d exit %d: %s%s\n", result.Node, result.ExitCode, result.Stdout, result.Stderr)
+		}
+	}
+	outcome := passStyle.Render("PASSED")
+	if !r.Passed {
+		outcome = failStyle.Render("FAILED")
+	}
+	fmt.Printf("Scenario %s in %v\n", outcome, r.Duration.Truncate(time.Millisecond))
+}
+
+// WriteJSON writes the report as JSON to the given file
+// It returns an error if the file can't be written
+func (r *ScenarioReport) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding the scenario report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing the scenario report: %v", err)
+	}
+	return nil
+}
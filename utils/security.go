@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ApplySecuritySettings sets the capabilities, the security options and the root filesystem mode of a host configuration
+// Unless the privileged mode is requested every capability is dropped except the ones granted by the settings
+// It returns an error if the seccomp profile can't be read
+func ApplySecuritySettings(hostConfig *container.HostConfig, settings config.SecuritySettings) error {
+	if settings.Privileged {
+		hostConfig.Privileged = true
+		return nil
+	}
+	hostConfig.CapDrop = []string{"ALL"}
+	hostConfig.CapAdd = settings.Capabilities()
+	hostConfig.ReadonlyRootfs = settings.ReadOnlyRootfs
+	switch settings.SeccompProfile {
+	case "":
+	case "unconfined":
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp=unconfined")
+	default:
+		// The daemon expects the content of the profile, not its path
+		profile, err := os.ReadFile(settings.SeccompProfile)
+		if err != nil {
+			return fmt.Errorf("error reading the seccomp profile: %v", err)
+		}
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+string(profile))
+	}
+	if settings.AppArmorProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "apparmor="+settings.AppArmorProfile)
+	}
+	if settings.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+	return nil
+}
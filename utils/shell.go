@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/moby/term"
+)
+
+// ShellOptions are the settings of an interactive shell opened in a node, the empty fields take the defaults of the node settings
+type ShellOptions struct {
+	Shell   string // Command of the shell, e.g. /bin/bash or "bash -l"
+	User    string // User (and group) running the shell
+	WorkDir string // Working directory of the shell
+}
+
+// ShellOptionsOf returns the shell options of the node settings, the default shell if none is set
+func ShellOptionsOf(settings config.NodeSettings) ShellOptions {
+	options := ShellOptions{Shell: settings.Shell, User: settings.User, WorkDir: settings.WorkDir}
+	if options.Shell == "" {
+		options.Shell = config.DefaultShell
+	}
+	return options
+}
+
+// ResolveShellNode returns the node given a pointer to the config struct and a container name (e.g. cont_erlang3), a node number or a target, the first node of a group or a selection
+// It returns an error if the node doesn't exist
+func ResolveShellNode(config *config.Config, target string) (int, error) {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "/")
+	if number, ok := strings.CutPrefix(target, containerNamePrefix(config.MeshName())); ok {
+		node, err := strconv.Atoi(number)
+		if err != nil || node < 0 || node >= config.TotalNodes() {
+			return 0, fmt.Errorf("the container %s is not a node of the virtual environment", target)
+		}
+		return node, nil
+	}
+	nodes, err := config.ResolveTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	if len(nodes) == 0 {
+		return 0, fmt.Errorf("the target %q has no nodes", target)
+	}
+	return nodes[0], nil
+}
+
+// OpenShell runs an interactive shell in a node attached to the standard input and output, the terminal is put in raw mode and its size follows the local one
+// It returns the exit code of the shell, or an error if the shell can't be started
+func OpenShell(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, options ShellOptions) (exitCode int, err error) {
+	start := time.Now()
+	defer func() {
+		recordActionDetails(OperationShell, nodeTarget(nodeNumber), fmt.Sprintf("%s, exit %d", options.Shell, exitCode), start, err)
+	}()
+	containerName := ContainerNameFromNodeNumber(nodeNumber, meshName)
+	inFd, isTerm := term.GetFdInfo(os.Stdin)
+	execOptions := container.ExecOptions{
+		Tty:          isTerm,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		User:         options.User,
+		WorkingDir:   options.WorkDir,
+		Cmd:          strings.Fields(options.Shell),
+	}
+	if isTerm {
+		if size, err := term.GetWinsize(inFd); err == nil {
+			execOptions.ConsoleSize = &[2]uint{uint(size.Height), uint(size.Width)}
+		}
+		if value := os.Getenv("TERM"); value != "" {
+			execOptions.Env = []string{"TERM=" + value}
+		}
+	}
+	exec, err := cli.ContainerExecCreate(ctx, containerName, execOptions)
+	if err != nil {
+		return 0, fmt.Errorf("error during the creation of the shell in the container %s: %v", containerName, err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{Tty: isTerm, ConsoleSize: execOptions.ConsoleSize})
+	if err != nil {
+		return 0, fmt.Errorf("error during the attach to the shell in the container %s: %v", containerName, err)
+	}
+	defer resp.Close()
+
+	if isTerm {
+		state, err := term.SetRawTerminal(inFd)
+		if err != nil {
+			return 0, fmt.Errorf("error setting the terminal in raw mode: %v", err)
+		}
+		defer term.RestoreTerminal(inFd, state)
+		resizeCtx, stopResize := context.WithCancel(ctx)
+		defer stopResize()
+		go followTerminalSize(resizeCtx, cli, exec.ID, inFd, execOptions.ConsoleSize)
+	}
+
+	go func() {
+		io.Copy(resp.Conn, os.Stdin)
+		resp.CloseWrite()
+	}()
+	// Without a TTY the output is multiplexed
+	if isTerm {
+		_, err = io.Copy(os.Stdout, resp.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(os.Stdout, os.Stderr, resp.Reader)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading the output of the shell in the container %s: %v", containerName, err)
+	}
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error during the inspection of the shell in the container %s: %v", containerName, err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// followTerminalSize resizes the TTY of an exec each time the local terminal is resized, until the context is done
+func followTerminalSize(ctx context.Context, cli *client.Client, execID string, fd uintptr, initial *[2]uint) {
+	if resizeSignal == nil {
+		return
+	}
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, resizeSignal)
+	defer signal.Stop(resized)
+	var height, width uint
+	if initial != nil {
+		height, width = initial[0], initial[1]
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resized:
+		}
+		size, err := term.GetWinsize(fd)
+		if err != nil || uint(size.Height) == height && uint(size.Width) == width {
+			continue
+		}
+		height, width = uint(size.Height), uint(size.Width)
+		// A failed resize is retried at the next change
+		cli.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: height, Width: width})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// resizeSignal is the signal sent when the terminal is resized
+var resizeSignal os.Signal = syscall.SIGWINCH
@@ -0,0 +1,6 @@
+package utils
+
+import "os"
+
+// resizeSignal is nil, Windows has no signal for the resize of the console and the size of the shell stays the initial one
+var resizeSignal os.Signal
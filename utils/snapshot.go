@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+var (
+	snapshotNameRegexp       = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,99}$`)
+	snapshotRepositoryRegexp = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// SnapshotNetwork is a network saved in a snapshot
+type SnapshotNetwork struct {
+	Network int    `json:"Network"`
+	Subnet  string `json:"Subnet"`
+}
+
+// SnapshotNode is a node saved in a snapshot, its filesystem is committed to an image
+type SnapshotNode struct {
+	Node     int            `json:"Node"`
+	Image    string         `json:"Image"`    // Image with the filesystem of the node
+	State    string         `json:"State"`    // running, paused, exited, ...
+	Networks map[int]string `json:"Networks"` // Address of the node on every network it was connected to
+}
+
+// Snapshot is a checkpoint of the virtual environment: the filesystems of the nodes, their addresses and their state, the topology and the faults
+type Snapshot struct {
+	Name          string            `json:"Name"`
+	Mesh          string            `json:"Mesh"`
+	Created       time.Time         `json:"Created"`
+	NumNetworks   int               `json:"NumNetworks"`
+	NumContainers int               `json:"NumContainers"`
+	NumLinks      int               `json:"NumLinks"`
+	Networks      []SnapshotNetwork `json:"Networks"`
+	Nodes         []SnapshotNode    `json:"Nodes"`
+	State         *MeshState        `json:"State"` // Adjacency matrix and active faults
+}
+
+// snapshotDir returns the directory of the snapshot files of the virtual environment, next to its state
+func snapshotDir(meshName string) string {
+	return filepath.Join(filepath.Dir(StateFilePath(meshName)), containerNamePrefix(meshName)+".snapshots")
+}
+
+// snapshotImage returns the reference of the image with the filesystem of a node in a snapshot
+// The project is a component of the repository path, e.g. containmesh/ci-42/erlang:before-3
+func snapshotImage(meshName string, name string, nodeNumber int) string {
+	var components []string
+	for _, part := range strings.Split(meshName, config.ProjectSeparator) {
+		components = append(components, strings.Trim(snapshotRepositoryRegexp.ReplaceAllString(strings.ToLower(part), "-"), "-._"))
+	}
+	return fmt.Sprintf("containmesh/%s:%s-%d", strings.Join(components, "/"), name, nodeNumber)
+}
+
+// validateSnapshotName checks that a snapshot name can be used as an image tag
+// It returns an error if the name is not valid
+func validateSnapshotName(name string) error {
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q, it must be made of letters, digits, '_', '.' or '-'", name)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot of the virtual environment given its name
+// It returns an error if the snapshot doesn't exist or can't be decoded
+func LoadSnapshot(meshName string, name string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(snapshotDir(meshName), name+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the snapshot %s doesn't exist", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the snapshot %s: %v", name, err)
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding the snapshot %s: %v", name, err)
+	}
+	if snapshot.State == nil {
+		snapshot.State = &MeshState{}
+	}
+	if snapshot.State.Faults == nil {
+		snapshot.State.Faults = map[int]FaultRecord{}
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of the virtual environment sorted by creation time
+// It returns an error if a snapshot can't be read
+func ListSnapshots(meshName string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir(meshName))
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the snapshots: %v", err)
+	}
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		snapshot, err := LoadSnapshot(meshName, name)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.Before(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+// CreateSnapshot commits the filesystem of every node to an image and saves their addresses and state, the subnets of the networks, the topology and the faults
+// The nodes are paused while their filesystem is committed
+// It returns an error if the name is not valid or already used, or if a node can't be inspected or committed
+func CreateSnapshot(cli *client.Client, config *config.Config, name string) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationSnapshot, "", name, start, err) }()
+	if err := validateSnapshotName(name); err != nil {
+		return err
+	}
+	meshName := config.MeshName()
+	path := filepath.Join(snapshotDir(meshName), name+".json")
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("the snapshot %s already exists, delete it first", name)
+	}
+	state, err := LoadState(meshName)
+	if err != nil {
+		return err
+	}
+	snapshot := Snapshot{Name: name, Mesh: meshName, Created: start, NumNetworks: *config.NumNetworks, NumContainers: *config.NumContainers,
+		NumLinks: *config.NumLinks, State: state}
+	// A failed snapshot doesn't leave the images committed so far behind
+	defer func() {
+		if err != nil {
+			removeSnapshotImages(cli, snapshot.Nodes)
+		}
+	}()
+	for n := 0; n < *config.NumNetworks; n++ {
+		inspect, err := cli.NetworkInspect(context.Background(), networkName(config, n), network.InspectOptions{})
+		if err != nil {
+			return fmt.Errorf("error during the inspection of the network %s: %v", networkName(config, n), err)
+		}
+		saved := SnapshotNetwork{Network: n}
+		if len(inspect.IPAM.Config) > 0 {
+			saved.Subnet = inspect.IPAM.Config[0].Subnet
+		}
+		snapshot.Networks = append(snapshot.Networks, saved)
+	}
+	for node := 0; node < config.TotalNodes(); node++ {
+		containerName := ContainerNameFromNodeNumber(node, meshName)
+		inspect, err := cli.ContainerInspect(context.Background(), containerName)
+		if err != nil {
+			return fmt.Errorf("error during the inspection of the container %s: %v", containerName, err)
+		}
+		saved := SnapshotNode{Node: node, Image: snapshotImage(meshName, name, node), State: inspect.State.Status, Networks: map[int]string{}}
+		for n := 0; n < *config.NumNetworks; n++ {
+			if endpoint, ok := inspect.NetworkSettings.Networks[networkName(config, n)]; ok {
+				saved.Networks[n] = endpoint.IPAddress
+			}
+		}
+		// A paused node is already frozen
+		_, err = cli.ContainerCommit(context.Background(), containerName, container.CommitOptions{
+			Reference: saved.Image,
+			Comment:   fmt.Sprintf("ContainMesh snapshot %s of node %d", name, node),
+			Pause:     inspect.State.Status == "running",
+		})
+		if err != nil {
+			return fmt.Errorf("error during the commit of the container %s: %v", containerName, err)
+		}
+		snapshot.Nodes = append(snapshot.Nodes, saved)
+		logf("Container %d committed to %s\n", node, saved.Image)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating the snapshot directory: %v", err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding the snapshot: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing the snapshot: %v", err)
+	}
+	logf("Snapshot %s of %d containers created successfully\n", name, len(snapshot.Nodes))
+	return nil
+}
+
+// RestoreSnapshot replaces the virtual environment with a snapshot: the networks get their subnets back, the nodes are recreated from their images with the same addresses and networks, so the cut links stay cut, and get their state back
+// The faults of the snapshot are restored and the latencies of the nodes and the links are applied again
+// It returns an error if the snapshot doesn't match the configuration or if the environment can't be recreated
+func RestoreSnapshot(cli *client.Client, config *config.Config, name string) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationRestore, "", name, start, err) }()
+	meshName := config.MeshName()
+	snapshot, err := LoadSnapshot(meshName, name)
+	if err != nil {
+		return err
+	}
+	if snapshot.NumNetworks != *config.NumNetworks || snapshot.NumContainers != *config.NumContainers || snapshot.NumLinks != *config.NumLinks {
+		return fmt.Errorf("the snapshot %s has %d networks of %d containers with %d links, it can't be restored with %d networks of %d containers with %d links",
+			name, snapshot.NumNetworks, snapshot.NumContainers, snapshot.NumLinks, *config.NumNetworks, *config.NumContainers, *config.NumLinks)
+	}
+	// Check the images before removing anything
+	for _, node := range snapshot.Nodes {
+		if _, _, err := cli.ImageInspectWithRaw(context.Background(), node.Image); err != nil {
+			return fmt.Errorf("error during the inspection of the image %s of the snapshot: %v", node.Image, err)
+		}
+	}
+
+	// The state of the snapshot is saved first, so the watcher doesn't take the removed and recreated nodes for faults
+	restoring := *snapshot.State
+	restoring.Restoring = true
+	if err := SaveState(meshName, &restoring); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			UpdateState(meshName, func(state *MeshState) { state.Restoring = false })
+		}
+	}()
+
+	containerIDs, networkIDs, err := meshResources(cli, config)
+	if err != nil {
+		return err
+	}
+	for _, id := range containerIDs {
+		if err := cli.ContainerRemove(context.Background(), id, container.RemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("error during the removal of the container %s: %v", id, err)
+		}
+	}
+	for _, id := range networkIDs {
+		if err := cli.NetworkRemove(context.Background(), id); err != nil {
+			return fmt.Errorf("error during the removal of the network %s: %v", id, err)
+		}
+	}
+
+	for _, saved := range snapshot.Networks {
+		labels := meshLabels(config)
+		labels[LabelNetwork] = fmt.Sprint(saved.Network)
+		subnet, err := netip.ParsePrefix(saved.Subnet)
+		if err != nil {
+			return fmt.Errorf("invalid subnet %q of the network %d in the snapshot", saved.Subnet, saved.Network)
+		}
+		if _, err := CreateNetwork(networkName(config, saved.Network), subnet, labels, cli, nil); err != nil {
+			return fmt.Errorf("error during the creation of the network %s: %v", networkName(config, saved.Network), err)
+		}
+	}
+	for _, saved := range snapshot.Nodes {
+		if err := restoreNode(cli, config, saved); err != nil {
+			return err
+		}
+	}
+
+	snapshot.State.Restoring = false
+	if err := SaveState(meshName, snapshot.State); err != nil {
+		return err
+	}
+	config.NetMatrix = snapshot.State.NetMatrix
+	logf("Snapshot %s of %d containers restored successfully\n", name, len(snapshot.Nodes))
+	return nil
+}
+
+// restoreNode creates a node from its snapshot image, connects it to its networks with its addresses and brings it back to its state
+// It returns an error if the container can't be created, connected or started
+func restoreNode(cli *client.Client, config *config.Config, saved SnapshotNode) (err error) {
+	containerName := ContainerNameFromNodeNumber(saved.Node, config.MeshName())
+	defer recordAction(OperationCreateContainer, containerName, time.Now(), &err)
+	home := saved.Node / *config.NumContainers
+	endpoint := func(n int) *network.EndpointSettings {
+		settings := &network.EndpointSettings{}
+		if address := saved.Networks[n]; address != "" {
+			settings.IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: address}
+		}
+		return settings
+	}
+	containerConfig := NodeContainerConfig(config, saved.Node)
+	containerConfig.Image = saved.Image
+	hostConfig, err := NodeHostConfig(config, saved.Node)
+	if err != nil {
+		return err
+	}
+	// The node is created on its own network, even if it was disconnected from it
+	_, err = cli.ContainerCreate(context.Background(), containerConfig, hostConfig, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{networkName(config, home): endpoint(home)},
+	}, nil, containerName)
+	if err != nil {
+		return fmt.Errorf("error during the creation of the container %s: %v", containerName, err)
+	}
+	for n := 0; n < *config.NumNetworks; n++ {
+		if _, ok := saved.Networks[n]; !ok || n == home {
+			continue
+		}
+		if err := cli.NetworkConnect(context.Background(), networkName(config, n), containerName, endpoint(n)); err != nil {
+			return fmt.Errorf("error during the connection of the container %s to the network %s: %v", containerName, networkName(config, n), err)
+		}
+	}
+	if _, ok := saved.Networks[home]; !ok {
+		if err := cli.NetworkDisconnect(context.Background(), networkName(config, home), containerName, true); err != nil {
+			return fmt.Errorf("error during the disconnection of the container %s from the network %s: %v", containerName, networkName(config, home), err)
+		}
+	}
+	if saved.State != "running" && saved.State != "paused" {
+		return nil
+	}
+	if err := cli.ContainerStart(context.Background(), containerName, container.StartOptions{}); err != nil {
+		return fmt.Errorf("error during the start of the container %s: %v", containerName, err)
+	}
+	// The latencies of the node and its links are lost with the interfaces, the state saved first has them
+	if err := ReapplyLatencies(cli, saved.Node, config.MeshName()); err != nil {
+		return err
+	}
+	if saved.State == "paused" {
+		if err := cli.ContainerPause(context.Background(), containerName); err != nil {
+			return fmt.Errorf("error during the pause of the container %s: %v", containerName, err)
+		}
+	}
+	return nil
+}
+
+// removeSnapshotImages removes the images of the nodes of a snapshot, the ones already removed are skipped
+// It returns an error if an image can't be removed
+func removeSnapshotImages(cli *client.Client, nodes []SnapshotNode) error {
+	for _, node := range nodes {
+		_, err := cli.ImageRemove(context.Background(), node.Image, image.RemoveOptions{PruneChildren: true})
+		if err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("error during the removal of the image %s: %v", node.Image, err)
+		}
+	}
+	return nil
+}
+
+// DeleteSnapshot removes the images and the file of a snapshot
+// It returns an error if the snapshot doesn't exist or an image can't be removed
+func DeleteSnapshot(cli *client.Client, config *config.Config, name string) (err error) {
+	start := time.Now()
+	defer func() { recordActionDetails(OperationDeleteSnapshot, "", name, start, err) }()
+	meshName := config.MeshName()
+	snapshot, err := LoadSnapshot(meshName, name)
+	if err != nil {
+		return err
+	}
+	if err := removeSnapshotImages(cli, snapshot.Nodes); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(snapshotDir(meshName), name+".json")); err != nil {
+		return fmt.Errorf("error removing the snapshot %s: %v", name, err)
+	}
+	logf("Snapshot %s deleted successfully\n", name)
+	return nil
+}
+
+// PrintSnapshots prints the snapshots of the virtual environment as a table
+func PrintSnapshots(snapshots []Snapshot) {
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshot of this environment")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tNODES\tRUNNING\tNETWORKS\tFAULTS")
+	for _, snapshot := range snapshots {
+		running := 0
+		for _, node := range snapshot.Nodes {
+			if node.State == "running" {
+				running++
+			}
+		}
+		faults := len(snapshot.State.Faults) + len(snapshot.State.LinkFaults)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", snapshot.Name, snapshot.Created.Format(time.DateTime), len(snapshot.Nodes), running, snapshot.NumNetworks, faults)
+	}
+	w.Flush()
+}
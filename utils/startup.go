@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+const (
+	maxParallelStarts        = 8               // Containers started at the same time
+	defaultDependencyTimeout = 5 * time.Minute // Time given to a dependency to be ready when no ready timeout is set
+)
+
+// WaitNodeReady waits until a node is healthy (or running if it has no health check)
+// It returns an error if the node exits or the timeout elapses
+func WaitNodeReady(ctx context.Context, cli *client.Client, nodeNumber int, meshName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := NodeHealth(cli, nodeNumber, meshName)
+		if err != nil {
+			return fmt.Errorf("error during the health check of the container %d: %v", nodeNumber, err)
+		}
+		if isReady(status) {
+			return nil
+		}
+		if isFailed(status) {
+			return fmt.Errorf("container %d %s before being ready", nodeNumber, status)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container %d not ready after %v", nodeNumber, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// StartContainers starts the containers following the dependencies between the nodes, the independent nodes are started concurrently
+// A node is started only when all its dependencies are ready
+// It returns the first error that occurs, the nodes not yet started are left stopped
+func StartContainers(cli *client.Client, config *config.Config, p *tea.Program) error {
+	dependencies, err := config.NodeDependencies()
+	if err != nil {
+		return err
+	}
+	timeout := defaultDependencyTimeout
+	if *config.ReadyTimeout > 0 {
+		timeout = *config.ReadyTimeout
+	}
+	// Only the nodes that have dependents must be waited for
+	hasDependents := map[int]bool{}
+	for _, nodes := range dependencies {
+		for _, node := range nodes {
+			hasDependents[node] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make([]chan struct{}, config.TotalNodes())
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	slots := make(chan struct{}, maxParallelStarts)
+	errc := make(chan error, config.TotalNodes())
+	var wg sync.WaitGroup
+	for node := 0; node < config.TotalNodes(); node++ {
+		wg.Add(1)
+		go func(node int) {
+			defer wg.Done()
+			for _, dependency := range dependencies[node] {
+				select {
+				case <-ready[dependency]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			slots <- struct{}{}
+			start := time.Now()
+			containerName := ContainerNameFromNodeNumber(node, config.MeshName())
+			err := cli.ContainerStart(ctx, containerName, container.StartOptions{})
+			recordActionDetails(OperationStartContainer, nodeTarget(node), "", start, err)
+			<-slots
+			if err != nil {
+				errc <- fmt.Errorf("error during the startup of the container: %v", err)
+				cancel()
+				return
+			}
+			sendResult(p, OperationStartContainer, time.Since(start), fmt.Sprintf("Container %s started successfully", containerName))
+			if hasDependents[node] {
+				if err := WaitNodeReady(ctx, cli, node, config.MeshName(), timeout); err != nil {
+					errc <- err
+					cancel()
+					return
+				}
+				sendResult(p, OperationNodeReady, time.Since(start), fmt.Sprintf("Container %s ready", containerName))
+			}
+			close(ready[node])
+		}(node)
+	}
+	wg.Wait()
+	close(errc)
+	return <-errc
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Faults that can be injected in a node
+const (
+	FaultStop    = "stop"
+	FaultPause   = "pause"
+	FaultKill    = "kill"
+	FaultRestart = "restart"
+	FaultLatency = "latency"
+	FaultCrash   = "crash" // The node stopped outside ContainMesh
+	FaultOOM     = "oom"   // The node was killed by the kernel out of memory killer
+)
+
+// FaultRecord describes a fault injected in a node
+type FaultRecord struct {
+	Node    int           `json:"Node"`
+	Fault   string        `json:"Fault"`
+	Signal  string        `json:"Signal,omitempty"`  // Signal sent by a kill fault
+	Delay   time.Duration `json:"Delay,omitempty"`   // Downtime of a restart fault
+	Latency time.Duration `json:"Latency,omitempty"` // Delay added by a latency fault
+	Since   time.Time     `json:"Since"`
+}
+
+func (f FaultRecord) String() string {
+	str := f.Fault
+	if f.Signal != "" {
+		str += " " + f.Signal
+	}
+	if f.Delay != 0 {
+		str += " for " + f.Delay.String()
+	}
+	if f.Latency != 0 {
+		str += " of " + f.Latency.String()
+	}
+	return str + " since " + f.Since.Format(time.TimeOnly)
+}
+
+// MeshState is the state of the virtual environment shared by the dashboard, the API and the command line
+type MeshState struct {
+	NetMatrix   [][]bool            `json:"NetMatrix,omitempty"`   // Adjacency matrix used to link the networks
+	Faults      map[int]FaultRecord `json:"Faults"`                // Active fault of every node
+	LinkFaults  []LinkFault         `json:"LinkFaults,omitempty"`  // Active link faults
+	Latencies   []LinkLatency       `json:"Latencies,omitempty"`   // Latencies of the links, set again when their interface is recreated
+	FaultCounts map[string]int      `json:"FaultCounts,omitempty"` // Faults injected since the creation, by kind
+	Restoring   bool                `json:"Restoring,omitempty"`   // Set while a snapshot is restored, the changes of the nodes are not faults
+}
+
+var stateMutex sync.Mutex // Serializes the updates of the state file in this process
+
+// StateFilePath returns the path of the file that stores the state of the virtual environment given the mesh name
+func StateFilePath(meshName string) string {
+	return filepath.Join(os.TempDir(), "containmesh", containerNamePrefix(meshName)+".json")
+}
+
+// LoadState reads the state of the virtual environment, an empty state if it has never been saved
+// It returns an error if the state file can't be read or decoded
+func LoadState(meshName string) (*MeshState, error) {
+	state := &MeshState{Faults: map[int]FaultRecord{}, FaultCounts: map[string]int{}}
+	data, err := os.ReadFile(StateFilePath(meshName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the state file: %v", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error decoding the state file: %v", err)
+	}
+	if state.Faults == nil {
+		state.Faults = map[int]FaultRecord{}
+	}
+	if state.FaultCounts == nil {
+		state.FaultCounts = map[string]int{}
+	}
+	return state, nil
+}
+
+// SaveState writes the state of the virtual environment, the file is replaced atomically
+// It returns an error if the state file can't be written
+func SaveState(meshName string, state *MeshState) error {
+	path := StateFilePath(meshName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating the state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding the state: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing the state file: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// UpdateState loads the state of the virtual environment, applies the update function and saves it
+// It returns an error if the state can't be loaded or saved
+func UpdateState(meshName string, update func(state *MeshState)) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	state, err := LoadState(meshName)
+	if err != nil {
+		return err
+	}
+	update(state)
+	return SaveState(meshName, state)
+}
+
+// RemoveState deletes the state file of the virtual environment
+// It returns an error if the file exists and can't be removed
+func RemoveState(meshName string) error {
+	err := os.Remove(StateFilePath(meshName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// recordFault saves the fault as the active fault of its node
+func recordFault(meshName string, fault FaultRecord) error {
+	return UpdateState(meshName, func(state *MeshState) {
+		state.Faults[fault.Node] = fault
+		state.FaultCounts[fault.Fault]++
+	})
+}
+
+// recordLinkFault saves a link fault as active
+func recordLinkFault(meshName string, fault LinkFault) error {
+	return UpdateState(meshName, func(state *MeshState) {
+		state.LinkFaults = append(state.LinkFaults, fault)
+		state.FaultCounts[fault.Fault]++
+	})
+}
+
+// clearFault removes the active fault of a node
+func clearFault(meshName string, nodeNumber int) error {
+	return UpdateState(meshName, func(state *MeshState) {
+		delete(state.Faults, nodeNumber)
+	})
+}
+
+// LoadTopology fills the adjacency matrix of the config struct with the one saved in the state, if it is not set
+// It is needed by the commands that run in a different process from the one that created the links
+// It returns an error if the state can't be loaded
+func LoadTopology(config *config.Config) error {
+	if config.NetMatrix != nil {
+		return nil
+	}
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return err
+	}
+	config.NetMatrix = state.NetMatrix
+	return nil
+}
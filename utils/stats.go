@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+)
+
+const (
+	DefaultStatsHistory = 120         // Samples kept per node, about two minutes
+	statsRetryInterval  = time.Second // Delay before streaming again the statistics of a node that is not running
+)
+
+// NodeStats is a sample of the resource usage of a node, the rates are computed from the previous sample
+type NodeStats struct {
+	Node           int       `json:"Node"`
+	Time           time.Time `json:"Time"`
+	CPU            float64   `json:"CPU"` // Percentage of one CPU
+	Memory         uint64    `json:"Memory"`
+	MemoryLimit    uint64    `json:"MemoryLimit"`
+	NetRx          uint64    `json:"NetRx"` // Total bytes received on every network
+	NetTx          uint64    `json:"NetTx"`
+	NetRxRate      float64   `json:"NetRxRate"` // Bytes per second
+	NetTxRate      float64   `json:"NetTxRate"`
+	BlockRead      uint64    `json:"BlockRead"`
+	BlockWrite     uint64    `json:"BlockWrite"`
+	BlockReadRate  float64   `json:"BlockReadRate"`
+	BlockWriteRate float64   `json:"BlockWriteRate"`
+	Pids           uint64    `json:"Pids"`
+}
+
+// MemoryPercent returns the memory usage as a percentage of the limit
+func (s NodeStats) MemoryPercent() float64 {
+	if s.MemoryLimit == 0 {
+		return 0
+	}
+	return float64(s.Memory) / float64(s.MemoryLimit) * 100
+}
+
+// newNodeStats converts a sample of the daemon into the statistics of a node given the previous sample, used for the rates
+func newNodeStats(nodeNumber int, response *container.StatsResponse, previous *NodeStats) NodeStats {
+	stats := NodeStats{Node: nodeNumber, Time: response.Read, MemoryLimit: response.MemoryStats.Limit, Pids: response.PidsStats.Current}
+	cpuDelta := float64(response.CPUStats.CPUUsage.TotalUsage) - float64(response.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(response.CPUStats.SystemUsage) - float64(response.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPU = cpuDelta / systemDelta * float64(response.CPUStats.OnlineCPUs) * 100
+	}
+	// The page cache is not counted, as done by docker stats
+	stats.Memory = response.MemoryStats.Usage
+	cache := response.MemoryStats.Stats["inactive_file"]
+	if cache == 0 {
+		cache = response.MemoryStats.Stats["cache"]
+	}
+	if cache < stats.Memory {
+		stats.Memory -= cache
+	}
+	for _, network := range response.Networks {
+		stats.NetRx += network.RxBytes
+		stats.NetTx += network.TxBytes
+	}
+	for _, entry := range response.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	if previous != nil {
+		elapsed := stats.Time.Sub(previous.Time).Seconds()
+		// rate returns the increase per second of a counter, a counter reset by a restart gives no rate
+		rate := func(current uint64, last uint64) float64 {
+			if elapsed <= 0 || current < last {
+				return 0
+			}
+			return float64(current-last) / elapsed
+		}
+		stats.NetRxRate = rate(stats.NetRx, previous.NetRx)
+		stats.NetTxRate = rate(stats.NetTx, previous.NetTx)
+		stats.BlockReadRate = rate(stats.BlockRead, previous.BlockRead)
+		stats.BlockWriteRate = rate(stats.BlockWrite, previous.BlockWrite)
+	}
+	return stats
+}
+
+// StatsCollector streams the resource usage of the nodes and keeps a rolling history of samples per node
+type StatsCollector struct {
+	cli       *client.Client
+	meshName  string
+	history   int
+	mutex     sync.RWMutex
+	samples   map[int][]NodeStats
+	listeners []func(NodeStats)
+}
+
+// NewStatsCollector creates a collector given a pointer to a Docker client, the mesh name and the number of samples kept per node
+func NewStatsCollector(cli *client.Client, meshName string, history int) *StatsCollector {
+	if history < 1 {
+		history = DefaultStatsHistory
+	}
+	return &StatsCollector{cli: cli, meshName: meshName, history: history, samples: map[int][]NodeStats{}}
+}
+
+// OnSample registers a function called with every new sample
+func (c *StatsCollector) OnSample(listener func(NodeStats)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+// add stores a sample, dropping the oldest one if the history is full
+func (c *StatsCollector) add(stats NodeStats) {
+	c.mutex.Lock()
+	samples := append(c.samples[stats.Node], stats)
+	if len(samples) > c.history {
+		samples = samples[len(samples)-c.history:]
+	}
+	c.samples[stats.Node] = samples
+	listeners := c.listeners
+	c.mutex.Unlock()
+	for _, listener := range listeners {
+		listener(stats)
+	}
+}
+
+// streamNode reads the samples of a node until the stream ends
+// It returns an error if the statistics can't be read
+func (c *StatsCollector) streamNode(ctx context.Context, nodeNumber int) error {
+	response, err := c.cli.ContainerStats(ctx, ContainerNameFromNodeNumber(nodeNumber, c.meshName), true)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	decoder := json.NewDecoder(response.Body)
+	var previous *NodeStats
+	for {
+		var sample container.StatsResponse
+		if err := decoder.Decode(&sample); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// A node that is not running sends empty samples
+		if sample.Read.IsZero() || sample.PidsStats.Current == 0 {
+			return nil
+		}
+		stats := newNodeStats(nodeNumber, &sample, previous)
+		previous = &stats
+		c.add(stats)
+	}
+}
+
+// Run streams the statistics of the nodes until the context is done
+// The stream of a node that is stopped or restarted is opened again, so the nodes are followed across the faults
+func (c *StatsCollector) Run(ctx context.Context, nodes []int) {
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node int) {
+			defer wg.Done()
+			for {
+				// The errors are not reported, a node that can't be read is shown without statistics
+				c.streamNode(ctx, node)
+				if !waitUntil(ctx, time.Now().Add(statsRetryInterval)) {
+					return
+				}
+			}
+		}(node)
+	}
+	wg.Wait()
+}
+
+// Latest returns the last sample of a node and false if the node has no samples
+func (c *StatsCollector) Latest(nodeNumber int) (NodeStats, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	samples := c.samples[nodeNumber]
+	if len(samples) == 0 {
+		return NodeStats{}, false
+	}
+	return samples[len(samples)-1], true
+}
+
+// LatestAll returns the last sample of every node that has one, sorted by node
+func (c *StatsCollector) LatestAll() []NodeStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	latest := []NodeStats{}
+	for _, samples := range c.samples {
+		if len(samples) > 0 {
+			latest = append(latest, samples[len(samples)-1])
+		}
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Node < latest[j].Node })
+	return latest
+}
+
+// History returns the samples of a node, the oldest first
+func (c *StatsCollector) History(nodeNumber int) []NodeStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]NodeStats{}, c.samples[nodeNumber]...)
+}
+
+// WaitSamples waits until every node has at least the given number of samples or the context is done
+func (c *StatsCollector) WaitSamples(ctx context.Context, nodes []int, count int) {
+	for {
+		ready := true
+		c.mutex.RLock()
+		for _, node := range nodes {
+			if len(c.samples[node]) < count {
+				ready = false
+				break
+			}
+		}
+		c.mutex.RUnlock()
+		if ready || !waitUntil(ctx, time.Now().Add(readyPollInterval)) {
+			return
+		}
+	}
+}
+
+// rate formats a rate in bytes per second
+func rate(bytesPerSecond float64) string {
+	return units.HumanSize(bytesPerSecond) + "/s"
+}
+
+// WriteStatsTable writes the samples as a table, the nodes without samples are shown empty
+func WriteStatsTable(w io.Writer, nodes []int, collector *StatsCollector) {
+	fmt.Fprintf(w, "%-6s %7s %21s %6s %11s %11s %11s %11s %5s\n", "NODE", "CPU", "MEMORY / LIMIT", "MEM", "NET RX", "NET TX", "BLOCK R", "BLOCK W", "PIDS")
+	for _, node := range nodes {
+		stats, ok := collector.Latest(node)
+		if !ok {
+			fmt.Fprintf(w, "%-6d %7s\n", node, "-")
+			continue
+		}
+		memory := units.BytesSize(float64(stats.Memory)) + " / " + units.BytesSize(float64(stats.MemoryLimit))
+		fmt.Fprintf(w, "%-6d %6.1f%% %21s %5.1f%% %11s %11s %11s %11s %5d\n", node, stats.CPU, memory, stats.MemoryPercent(),
+			rate(stats.NetRxRate), rate(stats.NetTxRate), rate(stats.BlockReadRate), rate(stats.BlockWriteRate), stats.Pids)
+	}
+}
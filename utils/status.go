@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// States of a node derived from the state of its container
+const (
+	NodeCreated    = "created"
+	NodeRunning    = "running"
+	NodePaused     = "paused"
+	NodeRestarting = "restarting"
+	NodeExited     = "exited"
+	NodeOOMKilled  = "oom-killed"
+	NodeDead       = "dead"
+	NodeMissing    = "missing" // The container doesn't exist
+)
+
+// NodeStatus is the live status of a node
+type NodeStatus struct {
+	Node     int          `json:"Node"`
+	State    string       `json:"State"`
+	ExitCode int          `json:"ExitCode,omitempty"` // Exit code of an exited or OOM killed node
+	Health   string       `json:"Health,omitempty"`   // Health status if the node has a health check
+	Fault    *FaultRecord `json:"Fault,omitempty"`    // Fault injected in the node
+}
+
+// Stopped reports whether the node is not running and can be started again
+func (s NodeStatus) Stopped() bool {
+	return s.State == NodeCreated || s.State == NodeExited || s.State == NodeOOMKilled || s.State == NodeDead
+}
+
+func (s NodeStatus) String() string {
+	str := s.State
+	if s.State == NodeExited || s.State == NodeOOMKilled {
+		str += fmt.Sprintf(" (%d)", s.ExitCode)
+	}
+	if s.Health != "" {
+		str += ", " + s.Health
+	}
+	if s.Fault != nil {
+		str += ", " + s.Fault.String()
+	}
+	return str
+}
+
+// nodeStatusFromState converts the state of a container into the status of a node
+func nodeStatusFromState(nodeNumber int, state *types.ContainerState) NodeStatus {
+	status := NodeStatus{Node: nodeNumber, State: state.Status}
+	switch {
+	case state.OOMKilled && !state.Running:
+		status.State = NodeOOMKilled
+		status.ExitCode = state.ExitCode
+	case state.Status == NodeExited:
+		status.ExitCode = state.ExitCode
+	}
+	if state.Health != nil {
+		status.Health = state.Health.Status
+	}
+	return status
+}
+
+// GetNodeStatus returns the live status of a node given a pointer to a Docker client, the node number and the mesh name
+// It returns an error if the container can't be inspected
+func GetNodeStatus(cli *client.Client, nodeNumber int, meshName string) (NodeStatus, error) {
+	info, err := cli.ContainerInspect(context.Background(), ContainerNameFromNodeNumber(nodeNumber, meshName))
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return NodeStatus{Node: nodeNumber, State: NodeMissing}, nil
+		}
+		return NodeStatus{}, err
+	}
+	if info.State == nil {
+		return NodeStatus{}, fmt.Errorf("the state of the container %d is not available", nodeNumber)
+	}
+	status := nodeStatusFromState(nodeNumber, info.State)
+	state, err := LoadState(meshName)
+	if err != nil {
+		return NodeStatus{}, err
+	}
+	status.Fault = activeFault(state, status)
+	return status, nil
+}
+
+// GetNodesStatus returns the live status of every node given a pointer to a Docker client and a pointer to the config struct
+// The containers are listed once and only the ones that are not running are inspected to get their exit code
+// It returns an error if the containers can't be listed or inspected
+func GetNodesStatus(cli *client.Client, config *config.Config) ([]NodeStatus, error) {
+	containers, err := cli.ContainerList(context.Background(), container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", containerNamePrefix(config.MeshName()))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]types.Container{}
+	for _, c := range containers {
+		for _, name := range c.Names {
+			byName[strings.TrimPrefix(name, "/")] = c
+		}
+	}
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]NodeStatus, config.TotalNodes())
+	for node := range statuses {
+		c, ok := byName[ContainerNameFromNodeNumber(node, config.MeshName())]
+		switch {
+		case !ok:
+			statuses[node] = NodeStatus{Node: node, State: NodeMissing}
+		case c.State == NodeRunning && !strings.Contains(c.Status, "health"):
+			statuses[node] = NodeStatus{Node: node, State: NodeRunning}
+			statuses[node].Fault = activeFault(state, statuses[node])
+		default:
+			statuses[node], err = GetNodeStatus(cli, node, config.MeshName())
+			if err != nil {
+				return nil, fmt.Errorf("error during the inspection of the container %d: %v", node, err)
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// GetStoppedContainers returns the nodes that are not running and can be started again
+// It returns an error if the status of the nodes can't be retrieved
+func GetStoppedContainers(cli *client.Client, config *config.Config) ([]int, error) {
+	statuses, err := GetNodesStatus(cli, config)
+	if err != nil {
+		return nil, err
+	}
+	stopped := []int{}
+	for _, status := range statuses {
+		if status.Stopped() {
+			stopped = append(stopped, status.Node)
+		}
+	}
+	return stopped, nil
+}
+
+// PrintNodesStatus prints the status of every node
+// It returns an error if the status of the nodes can't be retrieved
+func PrintNodesStatus(cli *client.Client, config *config.Config) error {
+	statuses, err := GetNodesStatus(cli, config)
+	if err != nil {
+		return err
+	}
+	fmt.Println("The status of the containers is:")
+	for _, status := range statuses {
+		fmt.Printf("%4d %s\n", status.Node, status)
+	}
+	state, err := LoadState(config.MeshName())
+	if err != nil {
+		return err
+	}
+	for _, fault := range state.LinkFaults {
+		fmt.Printf("%s of %d links since %s\n", fault.Fault, len(fault.Links), fault.Since.Format(time.TimeOnly))
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	streamPollInterval = time.Second // Interval between two reads of the status of the nodes
+	streamBufferSize   = 256         // Events kept for a slow subscriber before dropping the new ones
+)
+
+// Types of the events streamed by the API
+const (
+	StreamTopology = "topology" // Links cut or restored
+	StreamStatus   = "status"   // Node status transitions
+	StreamFault    = "fault"    // Faults injected or cleared
+	StreamStats    = "stats"    // Resource usage samples
+)
+
+// StreamTypes are the accepted event types of a stream filter
+var StreamTypes = []string{StreamTopology, StreamStatus, StreamFault, StreamStats}
+
+// StreamEvent is a change of the virtual environment pushed to the subscribers
+type StreamEvent struct {
+	Type  string    `json:"Type"`
+	Time  time.Time `json:"Time"`
+	Nodes []int     `json:"Nodes,omitempty"` // Nodes concerned by the event, none if it concerns the whole environment
+	Data  any       `json:"Data"`            // TopologyChange, StatusChange, FaultChange or NodeStats
+}
+
+// TopologyChange is the data of a topology event, the links of the adjacency matrix and the ones that are down
+type TopologyChange struct {
+	Links []Link `json:"Links"`
+	Down  []Link `json:"Down"`
+}
+
+// StatusChange is the data of a status event
+type StatusChange struct {
+	Previous string     `json:"Previous,omitempty"` // State before the transition, empty in the initial snapshot
+	Status   NodeStatus `json:"Status"`
+}
+
+// FaultChange is the data of a fault event, either a node fault or a link fault
+type FaultChange struct {
+	Fault     *FaultRecord `json:"Fault,omitempty"`
+	LinkFault *LinkFault   `json:"LinkFault,omitempty"`
+	Cleared   bool         `json:"Cleared"` // The fault was healed or is no longer active
+}
+
+// StreamFilter selects the events sent to a subscriber, an empty field selects everything
+type StreamFilter struct {
+	Nodes map[int]bool
+	Types map[string]bool
+}
+
+// ParseStreamFilter creates a filter given a pointer to the config struct, a target (e.g. "3", "net:1", a node group) and a comma separated list of event types
+// It returns an error if the target or an event type is not valid
+func ParseStreamFilter(config *config.Config, target string, types string) (StreamFilter, error) {
+	filter := StreamFilter{}
+	if target != "" {
+		nodes, err := config.ResolveTarget(target)
+		if err != nil {
+			return filter, err
+		}
+		filter.Nodes = map[int]bool{}
+		for _, node := range nodes {
+			filter.Nodes[node] = true
+		}
+	}
+	if types != "" {
+		filter.Types = map[string]bool{}
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(StreamTypes, t) {
+				return filter, fmt.Errorf("unknown event type %q, it must be one of %v", t, StreamTypes)
+			}
+			filter.Types[t] = true
+		}
+	}
+	return filter, nil
+}
+
+// Match reports whether an event is selected by the filter, the events without nodes match every node filter
+func (f StreamFilter) Match(event StreamEvent) bool {
+	if f.Types != nil && !f.Types[event.Type] {
+		return false
+	}
+	if f.Nodes == nil || len(event.Nodes) == 0 {
+		return true
+	}
+	for _, node := range event.Nodes {
+		if f.Nodes[node] {
+			return true
+		}
+	}
+	return false
+}
+
+// streamSubscriber is a client of the event hub
+type streamSubscriber struct {
+	filter StreamFilter
+	events chan StreamEvent
+}
+
+// EventHub detects the changes of the virtual environment and pushes them to its subscribers
+// The status of the nodes and the state are polled, so the changes made by every process are seen, the stats samples are pushed as they arrive
+type EventHub struct {
+	cli         *client.Client
+	config      *config.Config
+	mutex       sync.Mutex
+	polling     sync.Mutex // Serializes the polls of Run and Subscribe
+	subscribers map[*streamSubscriber]bool
+	wake        chan struct{} // Signaled by the first subscriber, the hub doesn't poll without subscribers
+	statuses    []NodeStatus
+	linkFaults  []LinkFault
+	down        map[Link]bool
+}
+
+// NewEventHub creates a hub given a pointer to a Docker client, a pointer to the config struct and the running stats collector
+func NewEventHub(cli *client.Client, config *config.Config, stats *StatsCollector) *EventHub {
+	h := &EventHub{cli: cli, config: config, subscribers: map[*streamSubscriber]bool{}, wake: make(chan struct{}, 1), down: map[Link]bool{}}
+	stats.OnSample(func(sample NodeStats) {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.publish(StreamEvent{Type: StreamStats, Time: sample.Time, Nodes: []int{sample.Node}, Data: sample})
+	})
+	return h
+}
+
+// Subscribe registers a subscriber and returns its events, starting with a snapshot of the topology and of the status of the nodes, and the function that unsubscribes it
+// The events are dropped if the subscriber doesn't read them fast enough
+func (h *EventHub) Subscribe(filter StreamFilter) (<-chan StreamEvent, func()) {
+	// The hub was idle, the snapshot is read again before it is sent
+	if h.idle() {
+		h.poll()
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+	now := time.Now()
+	snapshot := []StreamEvent{{Type: StreamTopology, Time: now, Data: h.topology()}}
+	for _, status := range h.statuses {
+		snapshot = append(snapshot, StreamEvent{Type: StreamStatus, Time: now, Nodes: []int{status.Node}, Data: StatusChange{Status: status}})
+	}
+	subscriber := &streamSubscriber{filter: filter, events: make(chan StreamEvent, streamBufferSize+len(snapshot))}
+	for _, event := range snapshot {
+		if filter.Match(event) {
+			subscriber.events <- event
+		}
+	}
+	h.subscribers[subscriber] = true
+	return subscriber.events, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		delete(h.subscribers, subscriber)
+	}
+}
+
+// publish sends an event to the subscribers that select it, the hub must be locked
+func (h *EventHub) publish(event StreamEvent) {
+	for subscriber := range h.subscribers {
+		if !subscriber.filter.Match(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+// topology returns the links of the virtual environment and the ones that are down, the hub must be locked
+func (h *EventHub) topology() TopologyChange {
+	topology := TopologyChange{Links: Links(h.config), Down: []Link{}}
+	for _, link := range topology.Links {
+		if h.down[link] {
+			topology.Down = append(topology.Down, link)
+		}
+	}
+	return topology
+}
+
+// idle reports whether the hub has no subscribers
+func (h *EventHub) idle() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers) == 0
+}
+
+// Run polls the virtual environment and publishes its changes while there are subscribers, until the context is done
+func (h *EventHub) Run(ctx context.Context) {
+	for {
+		if h.idle() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.wake:
+			}
+		}
+		// The errors are not reported, the environment is polled again
+		h.poll()
+		if !waitUntil(ctx, time.Now().Add(streamPollInterval)) {
+			return
+		}
+	}
+}
+
+// poll compares the status of the nodes and the link faults with the previous ones and publishes the differences
+// It returns an error if the status or the state can't be read
+func (h *EventHub) poll() error {
+	h.polling.Lock()
+	defer h.polling.Unlock()
+	statuses, err := GetNodesStatus(h.cli, h.config)
+	if err != nil {
+		return err
+	}
+	state, err := LoadState(h.config.MeshName())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, status := range statuses {
+		if i >= len(h.statuses) {
+			break
+		}
+		previous := h.statuses[i]
+		nodes := []int{status.Node}
+		if previous.State != status.State || previous.Health != status.Health || previous.ExitCode != status.ExitCode {
+			h.publish(StreamEvent{Type: StreamStatus, Time: now, Nodes: nodes, Data: StatusChange{Previous: previous.State, Status: status}})
+		}
+		if sameFault(previous.Fault, status.Fault) {
+			continue
+		}
+		if previous.Fault != nil {
+			h.publish(StreamEvent{Type: StreamFault, Time: now, Nodes: nodes, Data: FaultChange{Fault: previous.Fault, Cleared: true}})
+		}
+		if status.Fault != nil {
+			h.publish(StreamEvent{Type: StreamFault, Time: status.Fault.Since, Nodes: nodes, Data: FaultChange{Fault: status.Fault}})
+		}
+	}
+	h.statuses = statuses
+
+	// Link faults are identified by their kind, start and first link
+	for _, fault := range h.linkFaults {
+		if !slices.ContainsFunc(state.LinkFaults, fault.same) {
+			h.publish(StreamEvent{Type: StreamFault, Time: now, Nodes: linkNodes(fault.Links), Data: FaultChange{LinkFault: &fault, Cleared: true}})
+		}
+	}
+	for _, fault := range state.LinkFaults {
+		if !slices.ContainsFunc(h.linkFaults, fault.same) {
+			h.publish(StreamEvent{Type: StreamFault, Time: fault.Since, Nodes: linkNodes(fault.Links), Data: FaultChange{LinkFault: &fault}})
+		}
+	}
+	h.linkFaults = state.LinkFaults
+
+	down := map[Link]bool{}
+	for _, fault := range state.LinkFaults {
+		for _, link := range fault.Links {
+			down[link] = true
+		}
+	}
+	var changed []Link
+	for link := range down {
+		if !h.down[link] {
+			changed = append(changed, link)
+		}
+	}
+	for link := range h.down {
+		if !down[link] {
+			changed = append(changed, link)
+		}
+	}
+	h.down = down
+	if len(changed) > 0 {
+		h.publish(StreamEvent{Type: StreamTopology, Time: now, Nodes: linkNodes(changed), Data: h.topology()})
+	}
+	return nil
+}
+
+// sameFault reports whether two node faults are the same fault
+func sameFault(a *FaultRecord, b *FaultRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Fault == b.Fault && a.Since.Equal(b.Since)
+}
+
+// same reports whether two link faults are the same fault
+func (f LinkFault) same(other LinkFault) bool {
+	return f.Fault == other.Fault && f.Since.Equal(other.Since) && len(f.Links) == len(other.Links) &&
+		(len(f.Links) == 0 || f.Links[0] == other.Links[0])
+}
+
+// linkNodes returns the bridge nodes of the links, without duplicates
+func linkNodes(links []Link) []int {
+	var nodes []int
+	for _, link := range links {
+		if !slices.Contains(nodes, link.Node) {
+			nodes = append(nodes, link.Node)
+		}
+	}
+	slices.Sort(nodes)
+	return nodes
+}
@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"ContainMesh/config"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+const (
+	watchGracePeriod   = 3 * time.Second // Time given to ContainMesh to record its own faults before a change is judged unexpected
+	watchRetryInterval = time.Second     // Delay before subscribing again to the events of the daemon
+)
+
+// meshWatcher follows the events of the daemon about the containers and the networks of the virtual environment
+type meshWatcher struct {
+	cli      *client.Client
+	config   *config.Config
+	mutex    sync.Mutex
+	nodes    map[string]int // Node of every container ID
+	restarts map[int]int    // Nodes restarted by the policy
+}
+
+// nodeFromName returns the node of a container name and false if the container doesn't belong to the virtual environment
+func (w *meshWatcher) nodeFromName(name string) (int, bool) {
+	prefix := containerNamePrefix(w.config.MeshName())
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	node, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || node < 0 || node >= w.config.TotalNodes() {
+		return 0, false
+	}
+	return node, true
+}
+
+// networkFromName returns the number of a network name and false if the network doesn't belong to the virtual environment
+func (w *meshWatcher) networkFromName(name string) (int, bool) {
+	if !strings.HasPrefix(name, w.config.NetworkPrefix()) {
+		return 0, false
+	}
+	network, err := strconv.Atoi(strings.TrimPrefix(name, w.config.NetworkPrefix()))
+	if err != nil || network < 0 || network >= *w.config.NumNetworks {
+		return 0, false
+	}
+	return network, true
+}
+
+// loadContainers maps the IDs of the existing containers to their nodes, the network events only carry the container ID
+// It returns an error if the containers can't be listed
+func (w *meshWatcher) loadContainers(ctx context.Context) error {
+	containers, err := w.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", containerNamePrefix(w.config.MeshName()))),
+	})
+	if err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, c := range containers {
+		for _, name := range c.Names {
+			if node, ok := w.nodeFromName(strings.TrimPrefix(name, "/")); ok {
+				w.nodes[c.ID] = node
+			}
+		}
+	}
+	return nil
+}
+
+// exists reports whether a container still exists, the removed ones are forgotten
+func (w *meshWatcher) exists(ctx context.Context, containerID string) bool {
+	_, err := w.cli.ContainerInspect(ctx, containerID)
+	if client.IsErrNotFound(err) {
+		w.forget(containerID)
+		return false
+	}
+	return err == nil
+}
+
+// forget removes a container from the map of the nodes
+func (w *meshWatcher) forget(containerID string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.nodes, containerID)
+}
+
+// restoring reports whether a snapshot is being restored, the state can't be trusted until it is done
+func (w *meshWatcher) restoring() bool {
+	state, err := LoadState(w.config.MeshName())
+	return err != nil || state.Restoring
+}
+
+// watch reads the events of the daemon until the stream fails or the context is done
+// It returns an error if the stream fails
+func (w *meshWatcher) watch(ctx context.Context) error {
+	if err := w.loadContainers(ctx); err != nil {
+		return err
+	}
+	messages, errs := w.cli.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)), filters.Arg("type", string(events.NetworkEventType))),
+	})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case message := <-messages:
+			switch message.Type {
+			case events.ContainerEventType:
+				w.handleContainerEvent(ctx, message)
+			case events.NetworkEventType:
+				w.handleNetworkEvent(ctx, message)
+			}
+		}
+	}
+}
+
+// handleContainerEvent reacts to the events of the containers of the nodes
+func (w *meshWatcher) handleContainerEvent(ctx context.Context, message events.Message) {
+	node, ok := w.nodeFromName(message.Actor.Attributes["name"])
+	if !ok {
+		return
+	}
+	w.mutex.Lock()
+	w.nodes[message.Actor.ID] = node
+	w.mutex.Unlock()
+	switch message.Action {
+	case events.ActionOOM:
+		logf("%s\n", unhealthyStyle.Render(fmt.Sprintf("Container %d ran out of memory", node)))
+		journalEvent(Event{Time: time.Unix(0, message.TimeNano), Action: OperationNodeOOM, Target: nodeTarget(node), Outcome: OutcomeUnexpected})
+	case events.ActionDie:
+		exitCode, _ := strconv.Atoi(message.Actor.Attributes["exitCode"])
+		go w.handleDie(ctx, message.Actor.ID, node, exitCode, time.Unix(0, message.TimeNano))
+	case events.ActionStart:
+		// A node started outside ContainMesh is no longer crashed
+		UpdateState(w.config.MeshName(), func(state *MeshState) {
+			if fault, ok := state.Faults[node]; ok && (fault.Fault == FaultCrash || fault.Fault == FaultOOM) {
+				delete(state.Faults, node)
+			}
+		})
+	}
+}
+
+// handleDie checks whether a node that stopped was stopped by ContainMesh, otherwise it records the crash and restarts the node if the policy requires it
+func (w *meshWatcher) handleDie(ctx context.Context, containerID string, node int, exitCode int, since time.Time) {
+	if !waitUntil(ctx, time.Now().Add(watchGracePeriod)) {
+		return
+	}
+	meshName := w.config.MeshName()
+	// The containers removed since, e.g. by a restore, didn't crash
+	if !w.exists(ctx, containerID) || w.restoring() {
+		return
+	}
+	status, err := GetNodeStatus(w.cli, node, meshName)
+	if err != nil || !status.Stopped() || status.Fault != nil {
+		return
+	}
+	fault := FaultRecord{Node: node, Fault: FaultCrash, Since: since}
+	if status.State == NodeOOMKilled {
+		fault.Fault = FaultOOM
+	}
+	logf("%s\n", unhealthyStyle.Render(fmt.Sprintf("Container %d stopped unexpectedly, it is %s", node, status)))
+	journalEvent(Event{Time: since, Action: OperationNodeExit, Target: nodeTarget(node), Outcome: OutcomeUnexpected,
+		Details: fmt.Sprintf("%s, exit %d", status.State, exitCode)})
+	if err := recordFault(meshName, fault); err != nil {
+		logf("%s\n", errorStyle.Render(err.Error()))
+	}
+
+	w.mutex.Lock()
+	restarts := w.restarts[node]
+	restart := w.config.Watch.ShouldRestart(exitCode, fault.Fault == FaultOOM, restarts)
+	if restart {
+		w.restarts[node]++
+	}
+	w.mutex.Unlock()
+	if !restart {
+		return
+	}
+	logf("Container %d will be restarted in %v by the %s policy (restart %d)\n", node, w.config.Watch.RestartDelay, w.config.Watch.AutoRestart, restarts+1)
+	if !waitUntil(ctx, time.Now().Add(w.config.Watch.RestartDelay)) {
+		return
+	}
+	if err := RestartContainer(w.cli, node, meshName); err != nil {
+		logf("%s\n", errorStyle.Render(err.Error()))
+	}
+}
+
+// handleNetworkEvent reacts to the disconnections of the nodes from the networks of the virtual environment
+func (w *meshWatcher) handleNetworkEvent(ctx context.Context, message events.Message) {
+	if message.Action != events.ActionDisconnect {
+		return
+	}
+	network, ok := w.networkFromName(message.Actor.Attributes["name"])
+	if !ok {
+		return
+	}
+	containerID := message.Actor.Attributes["container"]
+	w.mutex.Lock()
+	node, ok := w.nodes[containerID]
+	w.mutex.Unlock()
+	if !ok {
+		return
+	}
+	go w.handleDisconnect(ctx, containerID, Link{Node: node, From: node / *w.config.NumContainers, To: network}, time.Unix(0, message.TimeNano))
+}
+
+// handleDisconnect checks whether a disconnection was made by ContainMesh, otherwise it records it as a link fault that can be healed
+// The container is inspected after the grace period, the link is only a fault if the container still exists, runs and is still out of the network
+func (w *meshWatcher) handleDisconnect(ctx context.Context, containerID string, link Link, since time.Time) {
+	if !waitUntil(ctx, time.Now().Add(watchGracePeriod)) {
+		return
+	}
+	meshName := w.config.MeshName()
+	// The disconnections of the removed, recreated or stopped containers are expected
+	info, err := w.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			w.forget(containerID)
+		}
+		return
+	}
+	if w.restoring() {
+		return
+	}
+	if info.State == nil || !info.State.Running || info.NetworkSettings == nil {
+		return
+	}
+	if _, connected := info.NetworkSettings.Networks[networkName(w.config, link.To)]; connected {
+		return
+	}
+	if link.From != link.To && !containsAnyLink(Links(w.config), []Link{link}) {
+		return
+	}
+	state, err := LoadState(meshName)
+	if err != nil {
+		return
+	}
+	for _, fault := range state.LinkFaults {
+		if containsAnyLink(fault.Links, []Link{link}) {
+			return
+		}
+	}
+	logf("%s\n", unhealthyStyle.Render(fmt.Sprintf("Container %d was disconnected from the network %d unexpectedly", link.Node, link.To)))
+	journalEvent(Event{Time: since, Action: OperationNodeDisconnect, Target: linkTarget(link), Outcome: OutcomeUnexpected})
+	if err := recordLinkFault(meshName, LinkFault{Fault: FaultDisconnect, Links: []Link{link}, Since: since}); err != nil {
+		logf("%s\n", errorStyle.Render(err.Error()))
+	}
+}
+
+// WatchEvents follows the events of the daemon about the virtual environment until the context is done
+// The nodes that stop or are disconnected outside ContainMesh are recorded as faults and reported, the stopped nodes are restarted according to the restart policy
+func WatchEvents(ctx context.Context, cli *client.Client, config *config.Config) {
+	w := &meshWatcher{cli: cli, config: config, nodes: map[string]int{}, restarts: map[int]int{}}
+	for {
+		// The errors are not reported, the stream is opened again
+		w.watch(ctx)
+		if !waitUntil(ctx, time.Now().Add(watchRetryInterval)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package utils
+
+import _ "embed"
+
+// webIndex is the single page web UI served on /ui, it draws the graph of the virtual environment from GET /graph and GET /stream
+//
+//go:embed web/index.html
+var webIndex []byte